@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/gridconn"
+)
+
+// analysisReport is the JSON document printed by -analyze with -output json
+type analysisReport struct {
+	Time     time.Time         `json:"time"`
+	Nodes    nodeSummary       `json:"nodes"`
+	Sessions []analyzedSession `json:"sessions"`
+	Total    int               `json:"total"`
+	Cleaned  int               `json:"cleaned"`
+	Blocked  string            `json:"blocked,omitempty"` // why the safety valve would stop the run
+}
+
+type analyzedSession struct {
+	SessionID string    `json:"sessionId"`
+	NodeIP    string    `json:"nodeIp"`
+	Browser   string    `json:"browser,omitempty"`
+	Platform  string    `json:"platform,omitempty"`
+	Start     time.Time `json:"start"`
+	Age       string    `json:"age"`
+	MaxAge    string    `json:"maxAge"`
+	Verdict   string    `json:"verdict"`
+	Clean     bool      `json:"clean"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// newAnalysisReport converts an analysis into its JSON report
+func newAnalysisReport(a *cleaner.Analysis) analysisReport {
+	report := analysisReport{
+		Time: a.Time,
+		Nodes: nodeSummary{
+			Inspected:    a.Nodes.Inspected,
+			WithSessions: a.Nodes.WithSessions,
+			WithoutSlots: append([]string{}, a.Nodes.WithoutSlots...),
+			Degraded:     a.Nodes.Degraded(),
+		},
+		Sessions: []analyzedSession{},
+		Total:    len(a.Sessions),
+		Cleaned:  a.Cleaned,
+	}
+	if a.Blocked != nil {
+		report.Blocked = a.Blocked.Error()
+	}
+	for _, s := range a.Sessions {
+		entry := analyzedSession{
+			SessionID: s.Session.SessionID,
+			NodeIP:    s.Session.NodeIP,
+			Browser:   s.Session.Browser,
+			Platform:  s.Session.Platform,
+			Start:     s.Session.StartTime,
+			Age:       s.Age.Round(time.Second).String(),
+			MaxAge:    s.MaxAge.String(),
+			Verdict:   string(s.Verdict),
+			Clean:     s.Verdict.Cleaned(),
+		}
+		if s.Err != nil {
+			entry.Error = s.Err.Error()
+		}
+		report.Sessions = append(report.Sessions, entry)
+	}
+	return report
+}
+
+// runAnalysis fetches the status once and prints what a cleanup run would do with it,
+// as a table or with format json as one line of JSON
+func runAnalysis(ctx context.Context, source *gridconn.Grid, c *cleaner.Cleaner, maxAge time.Duration, format string, w io.Writer) error {
+	status, err := source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	analysis, err := c.Analyze(ctx, status, maxAge)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		data, err := json.Marshal(newAnalysisReport(analysis))
+		if err != nil {
+			return fmt.Errorf("failed to encode analysis: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	return printAnalysis(w, analysis)
+}
+
+// printAnalysis writes the analysis as a table of sessions followed by a summary
+func printAnalysis(w io.Writer, a *cleaner.Analysis) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SESSION\tNODE\tBROWSER\tPLATFORM\tAGE\tMAX AGE\tVERDICT\tCLEAN")
+	for _, s := range a.Sessions {
+		verdict := string(s.Verdict)
+		if s.Err != nil {
+			verdict += " (" + s.Err.Error() + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\n", s.Session.SessionID, s.Session.NodeIP,
+			s.Session.Browser, s.Session.Platform, s.Age.Round(time.Second), s.MaxAge, verdict, s.Verdict.Cleaned())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nNodes: %d inspected, %d with sessions, %d without slots\n",
+		a.Nodes.Inspected, a.Nodes.WithSessions, len(a.Nodes.WithoutSlots))
+	fmt.Fprintf(w, "Sessions: %d of %d would be cleaned up at %s\n", a.Cleaned, len(a.Sessions), a.Time.Format(time.RFC3339))
+	if a.Blocked != nil {
+		fmt.Fprintf(w, "Safety valve would stop the run: %v\n", a.Blocked)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// envPrefix prefixes the environment variables that set flags, e.g. SELENIUM_CLEANER_NAMESPACE
+const envPrefix = "SELENIUM_CLEANER_"
+
+// flagEnvName returns the environment variable for a flag, e.g. SELENIUM_CLEANER_DELETE_RETRIES
+func flagEnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets every flag not given on the command line from its environment variable.
+// Values are parsed by the flag itself, so they behave exactly like the flag.
+func applyEnv(fs *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] {
+			return
+		}
+		name := flagEnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		// Setting through the flag set marks the flag as set, so the config file keeps off it
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, name, setErr)
+		}
+	})
+	return err
+}
+
+// entryFlag is implemented by map-valued flags so that a YAML mapping can fill them
+type entryFlag interface {
+	setEntry(key, value string) error
+}
+
+func (m durationMap) setEntry(key, value string) error {
+	return m.Set(key + "=" + value)
+}
+
+func (h headerMap) setEntry(key, value string) error {
+	return h.Set(key + ": " + value)
+}
+
+// loadConfigFile applies the settings of a YAML config file to the flags of fs. Keys are
+// flag names, e.g. "namespace" or "lifetime". Flags set on the command line or through the
+// environment keep their value; unknown keys are an error.
+//
+//	namespace: selenium
+//	lifetime: 1.5
+//	exclude-ips: [10.0.0.5, 10.1.0.0/16]
+//	lifetime-browser:
+//	  chrome: 4h
+func loadConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Numbers are kept as written, as float64 would turn e.g. 1000000 into 1e+06
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(data, &settings, useNumber); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	// Apply in a stable order so errors are reproducible
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f := fs.Lookup(key)
+		if f == nil || key == "config" {
+			return fmt.Errorf("unknown key %q in config file %s", key, path)
+		}
+		if explicit[key] {
+			continue
+		}
+		if err := setFlagValue(f, settings[key]); err != nil {
+			return fmt.Errorf("invalid value for %q in config file %s: %w", key, path, err)
+		}
+	}
+	return nil
+}
+
+// useNumber decodes JSON numbers as json.Number instead of float64
+func useNumber(d *json.Decoder) *json.Decoder {
+	d.UseNumber()
+	return d
+}
+
+// setFlagValue sets a flag from a decoded YAML value. Lists set each item in turn and
+// mappings are only accepted by map-valued flags.
+func setFlagValue(f *flag.Flag, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, item := range v {
+			if err := f.Value.Set(fmt.Sprint(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		entries, ok := f.Value.(entryFlag)
+		if !ok {
+			return fmt.Errorf("expected a single value, got a mapping")
+		}
+		for key, item := range v {
+			if err := entries.setEntry(key, fmt.Sprint(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return f.Value.Set(fmt.Sprint(v))
+	}
+}
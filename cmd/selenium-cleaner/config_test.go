@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+)
+
+// parseOptions parses args with the shared flags and the given config file content
+func parseOptions(t *testing.T, config string, args ...string) *options {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var opts options
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	opts.register(fs)
+	if err := opts.parse(fs, append([]string{"-config", path}, args...)); err != nil {
+		t.Fatalf("parse() error = %v", err)
+	}
+	return &opts
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	t.Setenv(flagEnvName("namespace"), "env-ns")
+	t.Setenv(flagEnvName("port"), "5555")
+	t.Setenv(flagEnvName("status-timeout"), "20s")
+
+	opts := parseOptions(t, `
+namespace: file-ns
+port: 6666
+service: file-router
+status-timeout: 10s
+`, "-namespace", "cli-ns")
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "command line over environment and file", got: opts.namespace, want: "cli-ns"},
+		{name: "environment over file", got: opts.port, want: 5555},
+		{name: "environment over file for durations", got: opts.download.Timeout, want: 20 * time.Second},
+		{name: "file over default", got: opts.service, want: "file-router"},
+		{name: "default", got: opts.targetKind, want: "service"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestConfigNumbers(t *testing.T) {
+	opts := parseOptions(t, `
+k8s-burst: 1000000
+k8s-qps: 2.5
+status-attempts: 10
+`)
+
+	if opts.k8sBurst != 1000000 {
+		t.Errorf("k8s-burst = %d, want 1000000", opts.k8sBurst)
+	}
+	if opts.k8sQPS != 2.5 {
+		t.Errorf("k8s-qps = %v, want 2.5", opts.k8sQPS)
+	}
+	if opts.download.Attempts != 10 {
+		t.Errorf("status-attempts = %d, want 10", opts.download.Attempts)
+	}
+}
+
+func TestConfigUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("namespace: selenium\nnamespaces: typo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var opts options
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	opts.register(fs)
+	if err := loadConfigFile(fs, path); err == nil {
+		t.Error("loadConfigFile() error = nil, want an error for the unknown key")
+	}
+}
+
+func TestOptionsBuildHTTPClientOnce(t *testing.T) {
+	opts := parseOptions(t, "", "-insecure-skip-tls-verify")
+	if opts.download.HTTPClient == nil {
+		t.Fatal("download.HTTPClient = nil, want the client built from the TLS flags")
+	}
+	client, err := downloader.NewHTTPClient(opts.download)
+	if err != nil {
+		t.Fatalf("NewHTTPClient() error = %v", err)
+	}
+	if client != opts.download.HTTPClient {
+		t.Error("NewHTTPClient() built a new client, want the one built by complete()")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/gridconn"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+// doctorCheck is a single self-test step
+type doctorCheck struct {
+	name string
+	hint string       // what to look at when the check fails
+	run  func() error // nil error means the check passed
+}
+
+// runDoctor exercises every external dependency without deleting anything and prints a report.
+// The grid is opened and its status fetched exactly like a cleanup run does, so -source,
+// -grid-scheme, -status-path and -status-file are honoured.
+func runDoctor(ctx context.Context, cancel context.CancelFunc, args []string) {
+	var opts options
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	opts.register(fs)
+	if err := opts.parse(fs, args); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	printConfig(opts.logFormat, opts.configParams())
+
+	var k8sClient *kubernetes.Client
+	var source *gridconn.Grid
+	defer func() {
+		if source != nil {
+			source.Close()
+		}
+	}()
+	connect := func() error {
+		var err error
+		source, err = gridconn.Open(ctx, opts.gridConfig(k8sClient))
+		return err
+	}
+
+	var checks []doctorCheck
+	if opts.useKubectl {
+		checks = append(checks, doctorCheck{
+			name: "kubectl available on PATH",
+			hint: "install kubectl and make sure it is on PATH, or drop -use-kubectl to use the native forwarder",
+			run: func() error {
+				_, err := exec.LookPath("kubectl")
+				return err
+			},
+		})
+	}
+	checks = append(checks,
+		doctorCheck{
+			name: "Kubernetes API access",
+			hint: "check the kubeconfig, the -context flag and that the credentials may list pods in the namespace",
+			run: func() error {
+				var err error
+				k8sClient, err = opts.newClient()
+				if err != nil {
+					return err
+				}
+				return k8sClient.CheckAccess(ctx)
+			},
+		},
+	)
+	if opts.statusFile == "" {
+		checks = append(checks, doctorCheck{
+			name: "Port-forward to grid service",
+			hint: "check that the -service and -port flags match the router service and that it has ready endpoints",
+			run:  connect,
+		})
+	}
+
+	fetch := doctorCheck{
+		name: "Grid status download",
+		hint: "check that the grid answers on -status-path with -grid-scheme and returns a Selenium Grid status document",
+	}
+	switch {
+	case opts.statusFile != "":
+		fetch.name = "Grid status file"
+		fetch.hint = "check that -status-file names a readable Selenium Grid status document"
+	case opts.statusSource == "graphql":
+		fetch.name = "Grid GraphQL query"
+		fetch.hint = "check that the grid serves /graphql with -grid-scheme, or use -source status"
+	}
+	fetch.run = func() error {
+		if source == nil {
+			if err := connect(); err != nil {
+				return err
+			}
+		}
+		status, err := source.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Info("Grid status fetched",
+			"nodes", len(status.Value.Nodes), "schema", status.Schema, "message", status.Value.Message)
+		return nil
+	}
+	checks = append(checks, fetch)
+
+	// Each check depends on the previous one, so stop at the first failure
+	failed := false
+	results := make([]string, 0, len(checks))
+	for _, check := range checks {
+		if failed {
+			results = append(results, fmt.Sprintf("[SKIP] %s", check.name))
+			continue
+		}
+		if err := check.run(); err != nil {
+			failed = true
+			results = append(results, fmt.Sprintf("[FAIL] %s: %v\n       hint: %s", check.name, err, check.hint))
+			continue
+		}
+		results = append(results, fmt.Sprintf("[PASS] %s", check.name))
+	}
+
+	fmt.Println("Selenium Grid Cleaner self-test:")
+	for _, result := range results {
+		fmt.Println("  " + result)
+	}
+
+	cancel()
+	if failed {
+		if source != nil {
+			source.Close()
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// durationMap is a flag value parsing comma-separated key=duration pairs, e.g. "chrome=4h,firefox=30m"
+type durationMap map[string]time.Duration
+
+func (m durationMap) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m durationMap) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid pair %q, expected key=duration", pair)
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration for %s: %w", key, err)
+		}
+		m[strings.TrimSpace(key)] = d
+	}
+	return nil
+}
+
+// stringList is a flag value collecting comma-separated values; repeating the flag appends
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// headerMap is a repeatable flag value parsing "Name: value" HTTP headers
+type headerMap map[string]string
+
+func (h headerMap) String() string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (h headerMap) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q, expected \"Name: value\"", value)
+	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	return nil
+}
+
+// optionalString is a string flag value remembering whether it was set, so that an explicit
+// empty value can be told apart from the default
+type optionalString struct {
+	value string
+	set   bool
+}
+
+func (s *optionalString) String() string {
+	if s == nil {
+		return ""
+	}
+	return s.value
+}
+
+func (s *optionalString) Set(value string) error {
+	s.value = value
+	s.set = true
+	return nil
+}
@@ -6,23 +6,45 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
 	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
 	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
-	"github.com/maxkulish/selenium-grid-cleaner/internal/portforwarder"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/metrics"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/notify"
 )
 
-func printConfig(params map[string]interface{}) {
-	var maxKeyLength int
-	for k := range params {
+// deletionLogFile is the file in the data directory remembering recent deletions
+const deletionLogFile = "recent-deletions.json"
+
+// printConfig logs the settings sorted by name: as a padded banner for the text log format
+// and as a single record with a config group of snake_case keys for json
+func printConfig(format string, params map[string]interface{}) {
+	keys := slices.Sorted(maps.Keys(params))
+
+	if format == "json" {
+		attrs := make([]any, 0, len(keys))
+		for _, k := range keys {
+			name := strings.ToLower(strings.ReplaceAll(k, " ", "_"))
+			attrs = append(attrs, slog.String(name, fmt.Sprint(params[k])))
+		}
+		slog.Info("Selenium Grid Cleaner configuration", "version", versionString(), slog.Group("config", attrs...))
+		return
+	}
+
+	maxKeyLength := len("Version")
+	for _, k := range keys {
 		if len(k) > maxKeyLength {
 			maxKeyLength = len(k)
 		}
@@ -31,14 +53,15 @@ func printConfig(params map[string]interface{}) {
 	var output strings.Builder
 	output.WriteString("\nSelenium Grid Cleaner Configuration:\n")
 	output.WriteString(strings.Repeat("=", 50) + "\n")
+	output.WriteString(fmt.Sprintf("%-*s : %s\n", maxKeyLength, "Version", versionString()))
 
-	for k, v := range params {
+	for _, k := range keys {
 		padding := strings.Repeat(" ", maxKeyLength-len(k))
-		output.WriteString(fmt.Sprintf("%s%s : %v\n", k, padding, v))
+		output.WriteString(fmt.Sprintf("%s%s : %v\n", k, padding, params[k]))
 	}
 	output.WriteString(strings.Repeat("=", 50) + "\n")
 
-	log.Print(output.String())
+	slog.Info(output.String())
 }
 
 func main() {
@@ -48,94 +71,456 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	// The first non-flag argument selects the subcommand; cleaning is the default
+	command, args := "clean", os.Args[1:]
+	if len(args) > 0 && (!strings.HasPrefix(args[0], "-") || isVersionFlag(args[0])) {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "version", "-version", "--version":
+		printVersion()
+	case "clean":
+		runClean(ctx, cancel, args)
+	case "reconcile":
+		runReconcile(ctx, cancel, args)
+	case "doctor":
+		runDoctor(ctx, cancel, args)
+	default:
+		log.Fatalf("Unknown command %q (expected clean, reconcile, doctor or version)", command)
+	}
+}
+
+// runClean deletes the pods of sessions that exceeded the configured lifetime
+func runClean(ctx context.Context, cancel context.CancelFunc, args []string) {
 	// Create a WaitGroup to ensure all cleanup is done before exiting
 	var wg sync.WaitGroup
 
 	// Command line flags
-	kubeContext := flag.String("context", "", "Kubernetes context to use")
-	seleniumGridPort := flag.Int("port", 4444, "Selenium Grid port")
-	seleniumGridNamespace := flag.String("namespace", "selenium", "Selenium Grid namespace")
-	seleniumGridServiceName := flag.String("service", "selenium-router", "Selenium Grid service name")
-	podLifetimeHours := flag.Float64("lifetime", 2.0, "Pod lifetime in hours")
-	flag.Parse()
-
-	// Log configuration parameters
-	config := map[string]interface{}{
-		"Kubernetes Context": func() string {
-			if *kubeContext == "" {
-				return "default from kubeconfig"
-			}
-			return *kubeContext
-		}(),
-		"Grid Port":      *seleniumGridPort,
-		"Grid Namespace": *seleniumGridNamespace,
-		"Grid Service":   *seleniumGridServiceName,
-		"Pod Lifetime":   fmt.Sprintf("%.1f hours", *podLifetimeHours),
-		"Kubeconfig": func() string {
-			if kc := os.Getenv("KUBECONFIG"); kc != "" {
-				return kc
-			}
-			home, err := os.UserHomeDir()
-			if err != nil {
-				return "unknown"
-			}
-			return filepath.Join(home, ".kube", "config")
-		}(),
+	var opts options
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	opts.register(fs)
+	podLifetimeHours := fs.Float64("lifetime", 2.0, "Pod lifetime in hours")
+	browserLifetimes := durationMap{}
+	fs.Var(browserLifetimes, "lifetime-browser", "Per-browser lifetime overrides, e.g. chrome=4h,firefox=30m")
+	minProtectedAge := fs.Duration("min-protected-age", 0, "Never clean up sessions younger than this, whatever -lifetime or -lifetime-browser say (0 disables)")
+	clockSkew := fs.Duration("clock-skew", 0, "Allowance for clock differences with the grid, subtracted from every session age")
+	gridClock := fs.Bool("grid-clock", false, "Compute session ages on the grid's clock, measured from the Date header of the status response")
+	ageSourceName := fs.String("age-source", "session", "What session ages are measured from: session (the start reported by the grid) or pod (the creation time of the session's pod)")
+	maxParallel := fs.Int("max-parallel", 10, "Maximum number of sessions cleaned up concurrently")
+	shutdownGrace := fs.Duration("shutdown-grace", 10*time.Second, "On SIGTERM or interrupt, how long deletions already in progress get to finish (0 stops them immediately)")
+	deletionTimeout := fs.Duration("deletion-timeout", 2*time.Minute, "How long to wait for a deleted pod to disappear")
+	sessionTimeout := fs.Duration("session-timeout", 3*time.Minute, "How long the cleanup of a single session may take before it is abandoned and its worker freed; keep it above -deletion-timeout (0 disables)")
+	gracePeriod := fs.Duration("grace-period", -1, "Termination grace period for deleted pods, 0 deletes immediately (negative keeps the pod's own)")
+	propagationPolicy := fs.String("propagation-policy", "foreground", "Propagation policy of pod deletions: foreground waits for dependents, background deletes the pod first, orphan keeps dependents")
+	cordon := fs.Bool("cordon", false, "Cordon the Kubernetes node hosting a pod before deleting the pod")
+	uncordon := fs.Bool("uncordon", false, "Uncordon nodes cordoned by -cordon once the pods of all sessions on them are handled")
+	emitEvents := fs.Bool("emit-events", false, "Record a Kubernetes event (reason SeleniumSessionCleaned) involving every deleted pod")
+	forceAfter := fs.Duration("force-after", 0, "Last resort: force delete pods still present this long after deletion, with a zero grace period (0 disables)")
+	forceFinalizers := fs.Bool("force-remove-finalizers", false, "Also remove the finalizers of pods force deleted by -force-after")
+	deleteRetries := fs.Int("delete-retries", 3, "Attempts for deleting a pod when the API server returns transient errors")
+	deleteRetryDelay := fs.Duration("delete-retry-delay", time.Second, "Initial delay between pod deletion attempts, doubled on each retry")
+	deleteQPS := fs.Float64("delete-qps", 0, "Maximum pod delete requests per second across all workers (0 disables the limit)")
+	deleteBurst := fs.Int("delete-burst", 1, "Delete requests allowed in a burst above -delete-qps")
+	deleteDebounce := fs.Duration("delete-debounce", 0, "Refuse to delete the same pod or session again within this window (0 disables)")
+	var excludeIPs stringList
+	fs.Var(&excludeIPs, "exclude-ips", "Comma-separated node IPs or CIDR ranges whose sessions are never cleaned")
+	var onlyIPs, onlySessions stringList
+	fs.Var(&onlyIPs, "only-ip", "Comma-separated node IPs or CIDR ranges; clean up only their sessions, whatever their age (repeatable)")
+	fs.Var(&onlySessions, "only-session", "Comma-separated session IDs; clean up only these sessions, whatever their age (repeatable)")
+	var excludeBrowsers stringList
+	fs.Var(&excludeBrowsers, "exclude-browser", "Comma-separated browser names whose sessions are never cleaned, e.g. edge,safari (case-insensitive)")
+	protectAnnotation := fs.String("protect-annotation", "selenium-cleaner/protect", "Pods with this annotation set to \"true\" are never deleted (empty disables)")
+	sessionLabel := fs.String("session-label", "", "Pod label holding the session ID, used to find the pod of a session when several share a node IP")
+	dryRun := fs.Bool("dry-run", false, "Report the pods that would be deleted without deleting them")
+	maxDeleteFraction := fs.Float64("max-delete-fraction", 0.8, "Safety valve: delete nothing when more than this share of all sessions (from 5 sessions on) exceeded the lifetime in one run (0 disables)")
+	maxDeleteCount := fs.Int("max-delete-count", 0, "Safety valve: delete nothing when more than this many sessions exceeded the lifetime in one run (0 disables)")
+	force := fs.Bool("force", false, "Disable the -max-delete-fraction and -max-delete-count safety valve")
+	skipDraining := fs.Bool("skip-draining", false, "Leave sessions alone on nodes that are draining or otherwise not UP")
+	resolveHostnames := fs.Bool("resolve-hostnames", false, "Clean up sessions of nodes registered by DNS name, finding their pod by name or by resolving the name to a pod IP (otherwise they are skipped)")
+	gracefulQuit := fs.Bool("graceful-quit", false, "Quit each session through the grid before deleting its pod")
+	quitTimeout := fs.Duration("quit-timeout", 10*time.Second, "Timeout for the graceful session quit request")
+	quitGrace := fs.Duration("quit-grace", 5*time.Second, "Time to wait after a graceful quit before deleting the pod")
+	quitViaNode := fs.Bool("quit-via-node", false, "Send the graceful quit to the session's node instead of the router, port-forwarding to the node pod outside the cluster; falls back to the router on failure")
+	output := fs.String("output", "text", "Result output: text logs only, or json to also print the result of each run to stdout")
+	reportFile := fs.String("report", "", "Write a report of every candidate session of each run to this file, or into this directory named like the status snapshot, e.g. the -data-dir (empty disables)")
+	reportFormat := fs.String("report-format", "json", "Format of the -report file: json or csv")
+	orphanSelector := fs.String("orphan-selector", "", "Label selector of node pods to check for orphans, pods of grid nodes with slots but no running session (empty disables)")
+	orphanAge := fs.Duration("orphan-age", 30*time.Minute, "Minimum age of a node pod without a session before it counts as orphaned")
+	deleteOrphans := fs.Bool("delete-orphans", false, "Delete orphaned node pods instead of only reporting them")
+	sessionID := fs.String("session-id", "", "Clean up only this session, found by its ID in the pod environment, whatever its age")
+	analyze := fs.Bool("analyze", false, "Print what a cleanup run would do with every session of the current status and exit, without looking up or deleting any pod")
+	interval := fs.Duration("interval", 0, "Keep running and clean up on this interval (0 runs once and exits)")
+	webhookURL := fs.String("webhook-url", "", "POST a summary of each cleanup run to this URL (empty disables)")
+	webhookFormat := fs.String("webhook-format", "json", "Webhook payload format: json or slack")
+	webhookTimeout := fs.Duration("webhook-timeout", 10*time.Second, "Timeout of the webhook request")
+	metricsAddr := fs.String("metrics-addr", "", "Serve Prometheus metrics on this address, e.g. :9090 (empty disables)")
+	healthAddr := fs.String("health-addr", "", "Serve a liveness check on this address under /healthz, e.g. :8081 (empty disables)")
+	healthMaxAge := fs.Duration("health-max-age", 0, "Report unhealthy once the last finished cleanup run is older than this (0 means three -interval periods, or 5m without -interval)")
+	pprofAddr := fs.String("pprof-addr", "", "Serve net/http/pprof profiles on this address, e.g. localhost:6060 (empty disables)")
+	if err := opts.parse(fs, args); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
-	printConfig(config)
-
-	podLifetime := time.Duration(*podLifetimeHours * float64(time.Hour))
-
-	log.Println("Starting port forwarder...")
-	// Port-forwarding
-	pf, err := portforwarder.NewPortForwarder(*seleniumGridNamespace, *seleniumGridServiceName, *seleniumGridPort)
+	if *sessionID != "" && *interval > 0 {
+		log.Fatalf("-session-id cleans up a single session and cannot be combined with -interval")
+	}
+	propagation, err := kubernetes.ParsePropagationPolicy(*propagationPolicy)
+	if err != nil {
+		log.Fatalf("Invalid -propagation-policy: %v", err)
+	}
+	if *analyze && (*sessionID != "" || *interval > 0) {
+		log.Fatalf("-analyze runs once over the whole status and cannot be combined with -session-id or -interval")
+	}
+	if *healthMaxAge <= 0 {
+		*healthMaxAge = 3 * *interval
+		if *interval <= 0 {
+			*healthMaxAge = 5 * time.Minute
+		}
+	}
+	if *output != "text" && *output != "json" {
+		log.Fatalf("Unknown -output %q (expected text or json)", *output)
+	}
+	if *reportFormat != "json" && *reportFormat != "csv" {
+		log.Fatalf("Unknown -report-format %q (expected json or csv)", *reportFormat)
+	}
+	ageSource, err := cleaner.ParseAgeSource(*ageSourceName)
 	if err != nil {
-		log.Fatalf("Failed to create port-forwarder: %v", err)
+		log.Fatalf("Invalid -age-source: %v", err)
 	}
 
-	// Add to WaitGroup before starting
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		<-ctx.Done()
-		log.Println("Shutting down port forwarder...")
-		pf.Stop()
+	// Log configuration parameters
+	config := opts.configParams()
+	config["Pod Lifetime"] = fmt.Sprintf("%.1f hours", *podLifetimeHours)
+	if len(browserLifetimes) > 0 {
+		config["Browser Lifetimes"] = browserLifetimes.String()
+	}
+	if *minProtectedAge > 0 {
+		config["Min Protected Age"] = *minProtectedAge
+	}
+	config["Age Source"] = ageSource
+	if *clockSkew > 0 || *gridClock {
+		config["Clock Skew"] = fmt.Sprintf("%v (grid clock: %t)", *clockSkew, *gridClock)
+	}
+	config["Max Parallel"] = *maxParallel
+	config["Deletion Timeout"] = *deletionTimeout
+	config["Session Timeout"] = *sessionTimeout
+	config["Shutdown Grace"] = *shutdownGrace
+	config["Propagation Policy"] = string(propagation)
+	config["Grace Period"] = func() string {
+		if *gracePeriod < 0 {
+			return "pod default"
+		}
+		return gracePeriod.String()
 	}()
-
-	if err := pf.Start(ctx); err != nil {
-		log.Fatalf("Failed to start port-forwarding: %v", err)
+	if *cordon {
+		config["Cordon Nodes"] = fmt.Sprintf("true (uncordon afterwards: %t)", *uncordon)
+	}
+	if *emitEvents {
+		config["Emit Events"] = true
+	}
+	if *forceAfter > 0 {
+		config["Force Delete After"] = fmt.Sprintf("%v (remove finalizers: %t)", *forceAfter, *forceFinalizers)
+	}
+	if *deleteQPS > 0 {
+		config["Delete Rate Limit"] = fmt.Sprintf("%g/s, burst %d", *deleteQPS, *deleteBurst)
+	}
+	config["Delete Debounce"] = *deleteDebounce
+	config["Dry Run"] = *dryRun
+	config["Safety Valve"] = func() string {
+		if *force {
+			return "disabled (-force)"
+		}
+		return fmt.Sprintf("max %.0f%% of sessions, max %d sessions (0 is unlimited)", *maxDeleteFraction*100, *maxDeleteCount)
+	}()
+	config["Skip Draining Nodes"] = *skipDraining
+	if *resolveHostnames {
+		config["Resolve Hostnames"] = true
+	}
+	config["Protect Annotation"] = *protectAnnotation
+	if *sessionLabel != "" {
+		config["Session Label"] = *sessionLabel
+	}
+	if len(excludeIPs) > 0 {
+		config["Excluded IPs"] = excludeIPs.String()
+	}
+	if len(onlyIPs) > 0 || len(onlySessions) > 0 {
+		config["Only Sessions"] = fmt.Sprintf("IPs [%s], IDs [%s]", onlyIPs.String(), onlySessions.String())
+	}
+	if len(excludeBrowsers) > 0 {
+		config["Excluded Browsers"] = excludeBrowsers.String()
 	}
+	config["Graceful Quit"] = *gracefulQuit
+	if *gracefulQuit && *quitViaNode {
+		config["Graceful Quit"] = "true (via node, router as fallback)"
+	}
+	if *sessionID != "" {
+		config["Session ID"] = *sessionID
+	}
+	if *orphanSelector != "" {
+		config["Orphan Pods"] = fmt.Sprintf("%s, older than %v (delete: %t)", *orphanSelector, *orphanAge, *deleteOrphans)
+	}
+	config["Interval"] = func() string {
+		if *interval <= 0 {
+			return "run once"
+		}
+		return interval.String()
+	}()
+	if *reportFile != "" {
+		config["Report"] = fmt.Sprintf("%s (%s)", *reportFile, *reportFormat)
+	}
+	if *metricsAddr != "" {
+		config["Metrics Address"] = *metricsAddr
+	}
+	if *healthAddr != "" {
+		config["Health Address"] = fmt.Sprintf("%s (max age %v)", *healthAddr, *healthMaxAge)
+	}
+	if *pprofAddr != "" {
+		config["Pprof Address"] = *pprofAddr
+	}
+	if *webhookURL != "" {
+		// The URL itself may embed a secret token, e.g. for Slack
+		config["Webhook"] = *webhookFormat
+	}
+	printConfig(opts.logFormat, config)
 
-	seleniumGridURL := fmt.Sprintf("http://localhost:%d/wd/hub/status", *seleniumGridPort)
-	localSeleniumGridURL := pf.GetLocalURL(seleniumGridURL)
+	podLifetime := time.Duration(*podLifetimeHours * float64(time.Hour))
 
-	log.Println("Downloading Selenium Grid status...")
-	// Download status.json
-	status, err := downloader.DownloadStatus(localSeleniumGridURL)
-	if err != nil {
-		log.Fatalf("Failed to download status: %v", err)
+	format, ok := notify.Formats[*webhookFormat]
+	if !ok {
+		log.Fatalf("Unknown -webhook-format %q (expected json or slack)", *webhookFormat)
 	}
+	notifier := &notify.Notifier{URL: *webhookURL, Format: format, Timeout: *webhookTimeout}
 
-	log.Println("Creating Kubernetes client...")
+	slog.Info("Creating Kubernetes client...")
 	// Kubernetes client
-	k8sClient, err := kubernetes.NewClient(*kubeContext, *seleniumGridNamespace)
+	k8sClient, err := opts.newClient()
 	if err != nil {
 		log.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
+	k8sClient.SetPropagationPolicy(propagation)
 
-	log.Println("Starting pod cleanup...")
+	source, err := openGrid(ctx, &opts, k8sClient, &wg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gridURL := source.URL()
+
+	slog.Info("Starting pod cleanup...")
 	// Clean pods
 	// Create the cleaner with configurable parallel operations
-	cleaner := cleaner.NewCleaner(k8sClient, 10) // 10 parallel operations max
-	if err := cleaner.CleanPods(ctx, status, podLifetime); err != nil {
-		log.Fatalf("Failed to clean pods: %v", err)
+	podCleaner := cleaner.NewCleaner(k8sClient, *maxParallel)
+	podCleaner.SetLogger(opts.logger)
+	podCleaner.SetDryRun(*dryRun)
+	if !*force {
+		podCleaner.SetSafetyValve(*maxDeleteFraction, *maxDeleteCount)
+	}
+	podCleaner.SetSkipDraining(*skipDraining)
+	podCleaner.SetBrowserMaxAges(browserLifetimes)
+	podCleaner.SetMinProtectedAge(*minProtectedAge)
+	podCleaner.SetClockSkew(*clockSkew, *gridClock)
+	podCleaner.SetAgeSource(ageSource)
+	podCleaner.SetResolveHostnames(*resolveHostnames)
+	podCleaner.SetProtectAnnotation(*protectAnnotation)
+	podCleaner.SetSessionLabel(*sessionLabel)
+	podCleaner.SetExcludeBrowsers(excludeBrowsers)
+	if err := podCleaner.SetOnly(onlyIPs, onlySessions); err != nil {
+		log.Fatalf("Invalid -only-ip: %v", err)
+	}
+	if err := podCleaner.SetExcludeIPs(excludeIPs); err != nil {
+		log.Fatalf("Invalid -exclude-ips: %v", err)
+	}
+	podCleaner.SetDeletionTimeout(*deletionTimeout)
+	podCleaner.SetSessionTimeout(*sessionTimeout)
+	podCleaner.SetDeleteGracePeriod(*gracePeriod)
+	podCleaner.SetDeleteRateLimit(*deleteQPS, *deleteBurst)
+	podCleaner.SetShutdownGrace(*shutdownGrace)
+	podCleaner.SetCordon(*cordon, *uncordon)
+	podCleaner.SetEmitEvents(*emitEvents)
+	podCleaner.SetForceDelete(*forceAfter, *forceFinalizers)
+	podCleaner.SetRetryPolicy(cleaner.RetryPolicy{
+		MaxAttempts: *deleteRetries,
+		BaseDelay:   *deleteRetryDelay,
+	})
+	if *gracefulQuit {
+		if gridURL == "" {
+			slog.Warn("Graceful quit needs a live grid, ignoring it with -status-file")
+		}
+		podCleaner.SetGracefulQuit(gridURL, *quitTimeout, *quitGrace)
+		if *quitViaNode {
+			// Node URIs are pod addresses, only reachable as they are from inside the cluster
+			var forward cleaner.NodeForward
+			if !kubernetes.InCluster() {
+				forward = newNodeForward(&opts, k8sClient)
+			}
+			podCleaner.SetQuitViaNode(true, forward)
+		}
+		podCleaner.SetHTTPClient(opts.download.HTTPClient)
+	}
+	if *deleteDebounce > 0 {
+		if opts.download.InMemory {
+			log.Fatalf("-delete-debounce keeps its log in the data directory and cannot be combined with -in-memory")
+		}
+		dataDir, err := downloader.DataDir(opts.download.DataDir)
+		if err != nil {
+			log.Fatalf("Failed to prepare data directory: %v", err)
+		}
+		if err := podCleaner.SetDeleteDebounce(*deleteDebounce, filepath.Join(dataDir, deletionLogFile)); err != nil {
+			log.Fatalf("Failed to load deletion log: %v", err)
+		}
+	}
+
+	if *metricsAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := metrics.Serve(ctx, *metricsAddr, podCleaner.Stats()); err != nil {
+				slog.Warn("Metrics server failed", "error", err)
+			}
+		}()
+	}
+	if *healthAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := metrics.ServeHealth(ctx, *healthAddr, podCleaner.Stats(), *healthMaxAge); err != nil {
+				slog.Warn("Health server failed", "error", err)
+			}
+		}()
+	}
+	if *pprofAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := metrics.ServePprof(ctx, *pprofAddr); err != nil {
+				slog.Warn("Pprof server failed", "error", err)
+			}
+		}()
+	}
+
+	// runOnce fetches the current status and cleans up the sessions that exceeded their lifetime
+	runOnce := func() error {
+		status, err := source.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		result, err := podCleaner.CleanPods(ctx, status, podLifetime)
+		if *output == "json" {
+			if err := writeCleanupReport(os.Stdout, result); err != nil {
+				slog.Warn("Failed to print cleanup report", "error", err)
+			}
+		}
+		if *reportFile != "" {
+			if path, err := writeRunReport(*reportFile, *reportFormat, result, status); err != nil {
+				slog.Warn("Failed to write run report", "error", err)
+			} else {
+				slog.Info("Wrote run report", "path", path)
+			}
+		}
+		if notifyErr := notifier.Notify(ctx, notify.NewSummary(result, *dryRun)); notifyErr != nil {
+			slog.Warn("Failed to send notification", "error", notifyErr)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clean pods: %w", err)
+		}
+		if result.Refused != nil {
+			return fmt.Errorf("cleanup refused: %w", result.Refused)
+		}
+		if *orphanSelector != "" {
+			if err := handleOrphans(ctx, podCleaner, status, *orphanSelector, *orphanAge, *deleteOrphans); err != nil {
+				return err
+			}
+		}
+		slog.Info("Cleanup run finished", "deleted", len(result.Deleted), "skipped", len(result.Skipped),
+			"nodes", result.Nodes.Inspected, "degraded", result.Nodes.Degraded(),
+			"duration", result.Duration.Round(time.Millisecond).String())
+		return nil
+	}
+
+	switch {
+	case *analyze:
+		if err := runAnalysis(ctx, source, podCleaner, podLifetime, *output, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	case *sessionID != "":
+		deleted, err := podCleaner.CleanSession(ctx, *sessionID)
+		if err != nil {
+			log.Fatalf("Failed to clean up session %s: %v", *sessionID, err)
+		}
+		slog.Info("Session cleanup finished", "session_id", *sessionID, "deleted", deleted)
+	case *interval <= 0:
+		if err := runOnce(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		runLoop(ctx, *interval, runOnce)
 	}
 
-	log.Println("Selenium cleaner finished successfully.")
+	slog.Info("Selenium cleaner finished successfully.")
 	// Cancel context to initiate cleanup
 	cancel()
 
 	// Wait for cleanup to complete
 	wg.Wait()
-	log.Println("Cleanup completed, exiting...")
+	slog.Info("Cleanup completed, exiting...")
+}
+
+// handleOrphans reports the node pods without a grid session, or deletes them with del
+func handleOrphans(ctx context.Context, c *cleaner.Cleaner, status *downloader.Status, selector string, minAge time.Duration, del bool) error {
+	if del {
+		deleted, err := c.CleanOrphans(ctx, status, selector, minAge)
+		if err != nil {
+			return fmt.Errorf("failed to clean orphaned pods: %w", err)
+		}
+		slog.Info("Orphaned pod cleanup finished", "deleted", len(deleted))
+		return nil
+	}
+
+	orphans, err := c.FindOrphans(ctx, status, selector, minAge)
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned pods: %w", err)
+	}
+	for _, orphan := range orphans {
+		slog.Warn("Node pod has no grid session", "namespace", orphan.Namespace, "pod", orphan.Name,
+			"pod_ip", orphan.IP, "age", orphan.Age.Round(time.Second).String())
+	}
+	slog.Info("Orphaned pods found", "count", len(orphans))
+	return nil
+}
+
+// runLoop calls run immediately and then every interval until ctx is cancelled, waiting
+// for a run in progress before it returns. A failed iteration is logged and the loop
+// carries on with the next one. Only one run is in progress at a time: a tick during a
+// slow run is skipped instead of starting an overlapping one.
+func runLoop(ctx context.Context, interval time.Duration, run func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var running atomic.Bool
+	var wg sync.WaitGroup
+	start := func() {
+		if !running.CompareAndSwap(false, true) {
+			slog.Warn("Skipping cleanup run, previous run still in progress", "interval", interval.String())
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer running.Store(false)
+			if err := run(); err != nil {
+				slog.Error("Cleanup run failed, retrying", "interval", interval.String(), "error", err)
+			}
+		}()
+	}
+
+	start()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopping cleanup loop...")
+			wg.Wait()
+			return
+		case <-ticker.C:
+			start()
+		}
+	}
 }
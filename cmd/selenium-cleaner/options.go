@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/gridconn"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/portforwarder"
+)
+
+// options holds the settings shared by all subcommands
+type options struct {
+	kubeContext string
+	kubeconfig  string
+	port        int
+	namespace   string // namespace of the grid router, the first of -namespace
+	service     string
+	localPort   int
+	statusPath  string
+	readiness   string         // readiness path of the forward, -readiness-path or else the status path
+	readinessOf optionalString // -readiness-path as given
+	useKubectl  bool
+
+	targetKind string
+	targetName string
+
+	podNamespaces []string // namespaces searched for node pods
+	allNamespaces bool
+	podSelector   string
+
+	forwardReconnects       int
+	forwardReconnectBackoff time.Duration
+	forwardStopGrace        time.Duration
+	forwardStartAttempts    int
+	forwardStartRetryDelay  time.Duration
+	forwardReadyInterval    time.Duration
+	forwardReadyTimeout     time.Duration
+	forwardExtraPorts       string
+	extraPorts              []int // further remote ports parsed from -forward-extra-ports
+
+	statusFile   string
+	statusSource string // status or graphql
+	gridScheme   string // http or https, the scheme the grid serves through the forward
+
+	k8sQPS   float64
+	k8sBurst int
+
+	download  downloader.Options
+	headers   headerMap
+	basicAuth string
+
+	configFile string
+
+	logFormat    string
+	logLevelName string
+	verbose      bool
+	quiet        bool
+	logger       *slog.Logger
+}
+
+// basicAuthEnv is the environment variable providing basic auth credentials as user:pass
+const basicAuthEnv = "SELENIUM_BASIC_AUTH"
+
+// register adds the shared flags to the flag set
+func (o *options) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.configFile, "config", "", "YAML file with flag values keyed by flag name; command-line flags take precedence")
+	fs.StringVar(&o.kubeContext, "context", "", "Kubernetes context to use")
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file, overriding KUBECONFIG and ~/.kube/config")
+	fs.StringVar(&o.logFormat, "log-format", "text", "Log format: text or json")
+	fs.StringVar(&o.logLevelName, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	fs.BoolVar(&o.verbose, "v", false, "Verbose output, shorthand for -log-level debug")
+	fs.BoolVar(&o.quiet, "q", false, "Quiet output, shorthand for -log-level warn")
+	fs.IntVar(&o.port, "port", 4444, "Selenium Grid port")
+	fs.Float64Var(&o.k8sQPS, "k8s-qps", 0, "Client-side QPS limit for Kubernetes API requests (0 keeps the client-go default of 5)")
+	fs.IntVar(&o.k8sBurst, "k8s-burst", 0, "Client-side burst for Kubernetes API requests (0 keeps the client-go default of 10)")
+	fs.StringVar(&o.namespace, "namespace", "selenium", "Selenium Grid namespace; a comma-separated list searches node pods in all of them, the first one running the router")
+	fs.StringVar(&o.podSelector, "pod-selector", "", "Label selector node pods must match to be cleaned up, e.g. purpose=ci (empty matches all pods)")
+	fs.BoolVar(&o.allNamespaces, "all-namespaces", false, "Search node pods in every namespace; -namespace still selects the router's namespace")
+	fs.StringVar(&o.service, "service", "selenium-router", "Selenium Grid service name")
+	fs.StringVar(&o.targetKind, "target-kind", "service", "Kind of resource to port-forward to: service or pod")
+	fs.StringVar(&o.targetName, "target-name", "", "Name of the service or pod to port-forward to (defaults to -service)")
+	fs.StringVar(&o.statusPath, "status-path", gridconn.DefaultStatusPath, "Path of the grid status endpoint, e.g. /status for a Grid 4 router without the /wd/hub prefix")
+	fs.Var(&o.readinessOf, "readiness-path", "HTTP `path` that must return 2xx through the port-forward before it is used (defaults to -status-path; set it empty to only check TCP)")
+	fs.IntVar(&o.localPort, "local-port", 0, "Local port for the port-forward (0 picks a free port)")
+	fs.BoolVar(&o.useKubectl, "use-kubectl", false, "Port-forward by shelling out to kubectl instead of the native client-go forwarder")
+	fs.StringVar(&o.statusSource, "source", "status", "Where to read sessions from: status (the REST /status endpoint) or graphql (the Grid 4 GraphQL endpoint)")
+	fs.StringVar(&o.statusFile, "status-file", "", "Read the grid status from this file instead of port-forwarding and downloading it")
+	defaults := downloader.DefaultOptions()
+	fs.DurationVar(&o.download.Timeout, "status-timeout", defaults.Timeout, "Timeout of a single status request (0 disables)")
+	fs.IntVar(&o.download.Attempts, "status-attempts", defaults.Attempts, "Attempts to download the status on connection errors and 5xx responses")
+	fs.DurationVar(&o.download.RetryDelay, "status-retry-delay", defaults.RetryDelay, "Initial delay between status download attempts, doubled on each retry")
+	fs.StringVar(&o.download.DataDir, "data-dir", "", "Directory for status snapshots and the deletion log (or set "+downloader.DataDirEnv+"; defaults to data next to the binary)")
+	fs.BoolVar(&o.download.InMemory, "in-memory", false, "Parse the grid status in memory without writing snapshots to disk")
+	fs.IntVar(&o.download.RetainCount, "retain-count", 0, "Keep at most this many status snapshots in the data directory (0 keeps all)")
+	fs.DurationVar(&o.download.RetainAge, "retain-age", 0, "Remove status snapshots older than this (0 keeps all)")
+	o.headers = headerMap{}
+	o.download.Headers = o.headers
+	fs.Var(o.headers, "header", "Extra HTTP header for grid requests as \"Name: value\" (repeatable)")
+	fs.StringVar(&o.gridScheme, "grid-scheme", "http", "Scheme the grid serves: http, or https for grids with TLS")
+	fs.BoolVar(&o.download.InsecureSkipVerify, "insecure-skip-tls-verify", false, "Skip TLS certificate verification of an HTTPS grid (staging only)")
+	fs.StringVar(&o.download.CACertFile, "ca-cert", "", "PEM file with a CA certificate to trust for an HTTPS grid")
+	fs.StringVar(&o.download.BearerToken, "auth-token", "", "Bearer token sent to the grid status endpoint")
+	fs.StringVar(&o.basicAuth, "basic-auth", "", "Basic auth credentials for the grid as user:pass (or set "+basicAuthEnv+")")
+	fs.IntVar(&o.forwardReconnects, "forward-reconnects", 0, "Times to restart the port-forward when it drops unexpectedly")
+	fs.IntVar(&o.forwardStartAttempts, "forward-start-attempts", 1, "Attempts to establish the initial port-forward")
+	fs.DurationVar(&o.forwardStartRetryDelay, "forward-start-retry-delay", 5*time.Second, "Delay between attempts to establish the initial port-forward")
+	fs.DurationVar(&o.forwardReadyInterval, "forward-ready-interval", 100*time.Millisecond, "Delay before the first readiness probe of a started port-forward, doubled after each failed probe up to 2s")
+	fs.DurationVar(&o.forwardReadyTimeout, "forward-ready-timeout", 30*time.Second, "How long a started port-forward may take to pass its readiness probe")
+	fs.DurationVar(&o.forwardStopGrace, "forward-stop-grace", 3*time.Second, "How long kubectl port-forward gets to exit after SIGTERM before it is killed")
+	fs.StringVar(&o.forwardExtraPorts, "forward-extra-ports", "", "Comma-separated list of further remote ports to forward alongside -port")
+	fs.DurationVar(&o.forwardReconnectBackoff, "forward-reconnect-backoff", time.Second, "Initial delay before restarting a dropped port-forward, doubled on each attempt")
+}
+
+// parse parses the command line, fills the flags left unset from the environment and then
+// from the config file, and completes the options
+func (o *options) parse(fs *flag.FlagSet, args []string) error {
+	fs.Parse(args)
+	if err := applyEnv(fs); err != nil {
+		return err
+	}
+	if o.configFile != "" {
+		if err := loadConfigFile(fs, o.configFile); err != nil {
+			return err
+		}
+	}
+	return o.complete()
+}
+
+// complete resolves settings that depend on several flags or the environment.
+// It must be called after the flag set has been parsed.
+func (o *options) complete() error {
+	for _, namespace := range strings.Split(o.namespace, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			o.podNamespaces = append(o.podNamespaces, namespace)
+		}
+	}
+	if len(o.podNamespaces) == 0 {
+		return fmt.Errorf("-namespace must name at least one namespace")
+	}
+	o.namespace = o.podNamespaces[0]
+
+	if o.statusSource != "status" && o.statusSource != "graphql" {
+		return fmt.Errorf("unknown -source %q (expected status or graphql)", o.statusSource)
+	}
+	if !strings.HasPrefix(o.statusPath, "/") {
+		return fmt.Errorf("-status-path must start with /, got %q", o.statusPath)
+	}
+	// The forward is ready once the endpoint the status is downloaded from answers
+	o.readiness = o.statusPath
+	if o.readinessOf.set {
+		o.readiness = o.readinessOf.value
+	}
+	if o.gridScheme != "http" && o.gridScheme != "https" {
+		return fmt.Errorf("unknown -grid-scheme %q (expected http or https)", o.gridScheme)
+	}
+	// One client serves the status requests, the quit requests and the readiness probe
+	httpClient, err := downloader.NewHTTPClient(o.download)
+	if err != nil {
+		return err
+	}
+	o.download.HTTPClient = httpClient
+
+	for _, port := range strings.Split(o.forwardExtraPorts, ",") {
+		if port = strings.TrimSpace(port); port == "" {
+			continue
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("invalid port %q in -forward-extra-ports", port)
+		}
+		o.extraPorts = append(o.extraPorts, n)
+	}
+
+	level, err := o.logLevel()
+	if err != nil {
+		return err
+	}
+	logger, err := newLogger(o.logFormat, level)
+	if err != nil {
+		return err
+	}
+	o.logger = logger
+	o.download.Logger = logger
+
+	if o.basicAuth == "" {
+		o.basicAuth = os.Getenv(basicAuthEnv)
+	}
+	if o.basicAuth != "" {
+		user, password, ok := strings.Cut(o.basicAuth, ":")
+		if !ok || user == "" {
+			return fmt.Errorf("basic auth credentials must be given as user:pass")
+		}
+		o.download.BasicAuthUser = user
+		o.download.BasicAuthPassword = password
+	}
+	return nil
+}
+
+// newLogger returns the logger for the given format and minimum level and installs it
+// as the default
+func newLogger(format string, level slog.Level) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		// The default slog handler writes through the log package and keeps its prefix
+		slog.SetLogLoggerLevel(level)
+		return slog.Default(), nil
+	case "json":
+		logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+		slog.SetDefault(logger)
+		// Only fatal errors still go through the log package; report them as error
+		// records so that they are neither filtered by the level nor left unstructured
+		log.SetFlags(0)
+		log.SetPrefix("")
+		log.SetOutput(logWriter{logger: logger, level: slog.LevelError})
+		return logger, nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+}
+
+// logWriter turns log package output into records of a fixed level
+type logWriter struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.logger.Log(context.Background(), w.level, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// logLevel resolves the minimum log level from -log-level and the -v/-q shorthands
+func (o *options) logLevel() (slog.Level, error) {
+	switch {
+	case o.verbose && o.quiet:
+		return 0, fmt.Errorf("-v and -q cannot be combined")
+	case o.verbose:
+		return slog.LevelDebug, nil
+	case o.quiet:
+		return slog.LevelWarn, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.logLevelName)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn or error)", o.logLevelName)
+	}
+	return level, nil
+}
+
+// configParams returns the shared settings in the form expected by printConfig
+func (o *options) configParams() map[string]interface{} {
+	return map[string]interface{}{
+		"Kubernetes Context": func() string {
+			if o.kubeContext == "" {
+				return "default from kubeconfig"
+			}
+			return o.kubeContext
+		}(),
+		"Kubernetes Rate Limit": func() string {
+			if o.k8sQPS <= 0 && o.k8sBurst <= 0 {
+				return "client-go default"
+			}
+			return fmt.Sprintf("%g QPS, burst %d", o.k8sQPS, o.k8sBurst)
+		}(),
+		"Grid Port":      o.port,
+		"Grid Namespace": o.namespace,
+		"Pod Namespaces": func() string {
+			if o.allNamespaces {
+				return "all"
+			}
+			return strings.Join(o.podNamespaces, ", ")
+		}(),
+		"Pod Selector": func() string {
+			if o.podSelector == "" {
+				return "all pods"
+			}
+			return o.podSelector
+		}(),
+		"Grid Service": o.service,
+		"Grid Scheme": func() string {
+			switch {
+			case o.gridScheme != "https":
+				return o.gridScheme
+			case o.download.InsecureSkipVerify:
+				return "https (certificate not verified)"
+			case o.download.CACertFile != "":
+				return "https (CA " + o.download.CACertFile + ")"
+			}
+			return "https"
+		}(),
+		"Forward Target": func() string {
+			name := o.targetName
+			if name == "" {
+				name = o.service
+			}
+			return o.targetKind + "/" + name
+		}(),
+		"Local Port": func() string {
+			if o.localPort == 0 {
+				return "auto"
+			}
+			return strconv.Itoa(o.localPort)
+		}(),
+		"Port Forward": func() string {
+			if o.useKubectl {
+				return "kubectl"
+			}
+			return "native"
+		}(),
+		"Auth Token": func() string {
+			if o.download.BearerToken == "" {
+				return "none"
+			}
+			return "<redacted>"
+		}(),
+		"Basic Auth": func() string {
+			if o.download.BasicAuthUser == "" {
+				return "none"
+			}
+			return o.download.BasicAuthUser + ":<redacted>"
+		}(),
+		"Extra Headers": func() string {
+			if len(o.headers) == 0 {
+				return "none"
+			}
+			// Only header names are shown, values may hold credentials
+			return o.headers.String()
+		}(),
+		"Status Source": func() string {
+			if o.statusFile != "" {
+				return o.statusFile
+			}
+			if o.statusSource == "status" {
+				return "grid " + o.statusPath + " via port-forward"
+			}
+			return "grid " + o.statusSource + " via port-forward"
+		}(),
+		"Data Directory": func() string {
+			if o.download.InMemory {
+				return "none (in-memory)"
+			}
+			if o.download.DataDir != "" {
+				return o.download.DataDir
+			}
+			if dir := os.Getenv(downloader.DataDirEnv); dir != "" {
+				return dir
+			}
+			return "default next to the binary"
+		}(),
+		"Kubeconfig": func() string {
+			if o.kubeconfig != "" {
+				return o.kubeconfig
+			}
+			if kc := os.Getenv("KUBECONFIG"); kc != "" {
+				return kc
+			}
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "unknown"
+			}
+			return filepath.Join(home, ".kube", "config")
+		}(),
+	}
+}
+
+// newClient creates the Kubernetes client for the configured context and namespaces
+func (o *options) newClient() (*kubernetes.Client, error) {
+	client, err := kubernetes.NewClient(o.kubeconfig, o.kubeContext, o.namespace,
+		kubernetes.WithRateLimit(float32(o.k8sQPS), o.k8sBurst))
+	if err != nil {
+		return nil, err
+	}
+	if o.allNamespaces {
+		client.SetPodNamespaces(nil)
+	} else {
+		client.SetPodNamespaces(o.podNamespaces)
+	}
+	if err := client.SetPodSelector(o.podSelector); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newPortForwarder creates the port-forwarder for the grid service, using the native
+// forwarder with the client's credentials unless kubectl was requested
+func newPortForwarder(opts *options, k8sClient *kubernetes.Client) (*portforwarder.PortForwarder, error) {
+	pf, err := portforwarder.NewPortForwarder(opts.namespace, opts.service, opts.port, opts.localPort)
+	if err != nil {
+		return nil, err
+	}
+	if opts.targetKind != string(portforwarder.TargetService) || opts.targetName != "" {
+		name := opts.targetName
+		if name == "" {
+			name = opts.service
+		}
+		if err := pf.SetTarget(portforwarder.TargetKind(opts.targetKind), name); err != nil {
+			return nil, err
+		}
+	}
+	if opts.useKubectl {
+		pf.SetKubeConfig(opts.kubeContext, opts.kubeconfig)
+	} else {
+		pf.UseNative(k8sClient.Config(), k8sClient.Clientset())
+	}
+	if err := pf.AddPorts(opts.extraPorts...); err != nil {
+		return nil, err
+	}
+	pf.SetLogger(opts.logger)
+	pf.SetReconnect(opts.forwardReconnects, opts.forwardReconnectBackoff)
+	pf.SetReadinessPath(opts.readiness)
+	if opts.gridScheme == "https" {
+		pf.SetProbeTLS(opts.download.HTTPClient)
+	}
+	pf.SetStopGrace(opts.forwardStopGrace)
+	pf.SetStartRetry(opts.forwardStartAttempts, opts.forwardStartRetryDelay)
+	pf.SetReadyWait(opts.forwardReadyInterval, 0, opts.forwardReadyTimeout)
+	return pf, nil
+}
+
+// newNodeForward returns a cleaner.NodeForward port-forwarding to the pod of a session's
+// node for as long as the quit request takes
+func newNodeForward(opts *options, k8sClient *kubernetes.Client) cleaner.NodeForward {
+	return func(ctx context.Context, session cleaner.SessionInfo) (string, func(), error) {
+		nodeURL, err := url.Parse(session.URI)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid node URI %q: %w", session.URI, err)
+		}
+		port, err := strconv.Atoi(nodeURL.Port())
+		if err != nil {
+			return "", nil, fmt.Errorf("node URI %q has no port", session.URI)
+		}
+
+		pf, err := portforwarder.NewPortForwarder(session.Namespace, session.PodName, port, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := pf.SetTarget(portforwarder.TargetPod, session.PodName); err != nil {
+			return "", nil, err
+		}
+		if opts.useKubectl {
+			pf.SetKubeConfig(opts.kubeContext, opts.kubeconfig)
+		} else {
+			pf.UseNative(k8sClient.Config(), k8sClient.Clientset())
+		}
+		pf.SetLogger(opts.logger)
+		if err := pf.Start(ctx); err != nil {
+			pf.Stop()
+			return "", nil, err
+		}
+		return fmt.Sprintf("%s://localhost:%d", nodeURL.Scheme, pf.LocalPort()), pf.Stop, nil
+	}
+}
+
+// gridConfig returns the settings for opening the grid with the given client
+func (o *options) gridConfig(k8sClient *kubernetes.Client) gridconn.Config {
+	return gridconn.Config{
+		KubeContext: o.kubeContext,
+		Kubeconfig:  o.kubeconfig,
+		Namespace:   o.namespace,
+		Service:     o.service,
+		Port:        o.port,
+		Scheme:      o.gridScheme,
+		Source:      o.statusSource,
+		StatusPath:  o.statusPath,
+		StatusFile:  o.statusFile,
+		Download:    o.download,
+		Client:      k8sClient,
+		Logger:      o.logger,
+		NewForwarder: func(client *kubernetes.Client) (gridconn.Forwarder, error) {
+			pf, err := newPortForwarder(o, client)
+			if err != nil {
+				return nil, err
+			}
+			return pf, nil
+		},
+	}
+}
+
+// openGrid starts the port-forward to the grid service unless the status is read from a
+// file. The port-forwarder is stopped once ctx is cancelled; wg tracks that shutdown.
+func openGrid(ctx context.Context, opts *options, k8sClient *kubernetes.Client, wg *sync.WaitGroup) (*gridconn.Grid, error) {
+	g, err := gridconn.Open(ctx, opts.gridConfig(k8sClient))
+	if err != nil {
+		return nil, err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		g.Close()
+	}()
+	return g, nil
+}
+
+// fetchStatus opens the status source and fetches the status once. It also returns
+// the local WebDriver base URL of the grid for further requests through the forward.
+// With -status-file the status is read from disk and no grid URL is available.
+func fetchStatus(ctx context.Context, opts *options, k8sClient *kubernetes.Client, wg *sync.WaitGroup) (*downloader.Status, string, error) {
+	g, err := openGrid(ctx, opts, k8sClient, wg)
+	if err != nil {
+		return nil, "", err
+	}
+	status, err := g.Fetch(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return status, g.URL(), nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+)
+
+// cleanupReport is the JSON document printed with -output json
+type cleanupReport struct {
+	StartTime time.Time        `json:"startTime"`
+	Duration  string           `json:"duration"`
+	Deleted   []string         `json:"deleted"`
+	Sessions  []deletedSession `json:"deletedSessions"`
+	Skipped   []skippedSession `json:"skipped"`
+	Failed    []failedSession  `json:"failed"`
+	TimedOut  []string         `json:"timedOut"` // IDs of the failed sessions that hit -session-timeout
+	Nodes     nodeSummary      `json:"nodes"`
+	Refused   string           `json:"refused,omitempty"` // why the safety valve stopped the run
+}
+
+type nodeSummary struct {
+	Inspected    int      `json:"inspected"`
+	WithSessions int      `json:"withSessions"`
+	WithoutSlots []string `json:"withoutSlots"` // URIs of nodes reported without slots
+	Degraded     bool     `json:"degraded"`
+}
+
+type deletedSession struct {
+	SessionID string `json:"sessionId"`
+	NodeIP    string `json:"nodeIp"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace,omitempty"`
+	Browser   string `json:"browser,omitempty"`
+	Platform  string `json:"platform,omitempty"`
+}
+
+type skippedSession struct {
+	SessionID string `json:"sessionId"`
+	NodeIP    string `json:"nodeIp"`
+	Pod       string `json:"pod,omitempty"`
+	Browser   string `json:"browser,omitempty"`
+	Platform  string `json:"platform,omitempty"`
+	Age       string `json:"age"`
+}
+
+type failedSession struct {
+	SessionID string `json:"sessionId"`
+	Error     string `json:"error"`
+}
+
+// newCleanupReport converts a cleanup result into its JSON report
+func newCleanupReport(result *cleaner.CleanupResult) cleanupReport {
+	report := cleanupReport{
+		StartTime: result.StartTime,
+		Duration:  result.Duration.Round(time.Millisecond).String(),
+		Deleted:   append([]string{}, result.Deleted...),
+		Sessions:  []deletedSession{},
+		Skipped:   []skippedSession{},
+		Failed:    []failedSession{},
+		TimedOut:  append([]string{}, result.TimedOut...),
+		Nodes: nodeSummary{
+			Inspected:    result.Nodes.Inspected,
+			WithSessions: result.Nodes.WithSessions,
+			WithoutSlots: append([]string{}, result.Nodes.WithoutSlots...),
+			Degraded:     result.Nodes.Degraded(),
+		},
+	}
+	for _, session := range result.Sessions {
+		report.Sessions = append(report.Sessions, deletedSession{
+			SessionID: session.SessionID,
+			NodeIP:    session.NodeIP,
+			Pod:       session.PodName,
+			Namespace: session.Namespace,
+			Browser:   session.Browser,
+			Platform:  session.Platform,
+		})
+	}
+	for _, session := range result.Skipped {
+		report.Skipped = append(report.Skipped, skippedSession{
+			SessionID: session.SessionID,
+			NodeIP:    session.NodeIP,
+			Pod:       session.PodName,
+			Browser:   session.Browser,
+			Platform:  session.Platform,
+			Age:       session.Age.Round(time.Second).String(),
+		})
+	}
+	for sessionID, err := range result.Failed {
+		report.Failed = append(report.Failed, failedSession{SessionID: sessionID, Error: err.Error()})
+	}
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].SessionID < report.Failed[j].SessionID })
+	if result.Refused != nil {
+		report.Refused = result.Refused.Error()
+	}
+	return report
+}
+
+// writeCleanupReport prints the result as a single line of JSON
+func writeCleanupReport(w io.Writer, result *cleaner.CleanupResult) error {
+	data, err := json.Marshal(newCleanupReport(result))
+	if err != nil {
+		return fmt.Errorf("failed to encode cleanup report: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+)
+
+func TestCleanupReportUsesDecisionAge(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	result := &cleaner.CleanupResult{
+		StartTime: start.Add(3 * time.Hour), // unrelated to the clock the cleaner decided with
+		Skipped:   []cleaner.SessionInfo{{SessionID: "s1", StartTime: start, Age: 55 * time.Minute}},
+	}
+
+	report := newCleanupReport(result)
+	if len(report.Skipped) != 1 || report.Skipped[0].Age != "55m0s" {
+		t.Errorf("skipped = %+v, want s1 aged 55m0s", report.Skipped)
+	}
+}
+
+func TestCleanupReportRefused(t *testing.T) {
+	if report := newCleanupReport(&cleaner.CleanupResult{}); report.Refused != "" {
+		t.Errorf("Refused = %q, want empty", report.Refused)
+	}
+
+	refused := fmt.Errorf("%w: 6 of 6 sessions, more than 50%%", cleaner.ErrTooManyDeletions)
+	report := newCleanupReport(&cleaner.CleanupResult{Refused: refused})
+	if report.Refused != refused.Error() {
+		t.Errorf("Refused = %q, want %q", report.Refused, refused.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+)
+
+// runReconcile reports drift between grid sessions and node pods without deleting anything
+func runReconcile(ctx context.Context, cancel context.CancelFunc, args []string) {
+	var wg sync.WaitGroup
+
+	var opts options
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	opts.register(fs)
+	nodeSelector := fs.String("node-selector", "", "Label selector matching Selenium node pods (empty matches every pod in the namespace)")
+	if err := opts.parse(fs, args); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	config := opts.configParams()
+	config["Node Selector"] = *nodeSelector
+	printConfig(opts.logFormat, config)
+
+	slog.Info("Creating Kubernetes client...")
+	k8sClient, err := opts.newClient()
+	if err != nil {
+		log.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	status, _, err := fetchStatus(ctx, &opts, k8sClient, &wg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.Info("Reconciling grid sessions with node pods...")
+	reconciler := cleaner.NewCleaner(k8sClient, 1)
+	reconciler.SetLogger(opts.logger)
+	report, err := reconciler.Reconcile(ctx, status, *nodeSelector)
+	if err != nil {
+		log.Fatalf("Failed to reconcile: %v", err)
+	}
+	printReconciliation(report)
+
+	cancel()
+	wg.Wait()
+}
+
+// printReconciliation writes the reconciliation report to stdout
+func printReconciliation(r *cleaner.Reconciliation) {
+	fmt.Printf("Matched sessions (%d):\n", len(r.Matched))
+	for _, s := range r.Matched {
+		fmt.Printf("  %s  node=%s  pod=%s/%s  started=%s\n", s.SessionID, s.NodeIP, s.Namespace, s.PodName, s.StartTime.Format(time.RFC3339))
+	}
+
+	fmt.Printf("Orphaned sessions, no pod found (%d):\n", len(r.Orphaned))
+	for _, s := range r.Orphaned {
+		fmt.Printf("  %s  node=%s  started=%s\n", s.SessionID, s.NodeIP, s.StartTime.Format(time.RFC3339))
+	}
+
+	fmt.Printf("Unregistered pods, no grid session (%d):\n", len(r.Unregistered))
+	for _, p := range r.Unregistered {
+		fmt.Printf("  %s/%s  ip=%s\n", p.Namespace, p.Name, p.IP)
+	}
+}
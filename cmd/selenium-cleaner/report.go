@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+)
+
+// runReport is the audit report of a cleanup run written with -report
+type runReport struct {
+	StartTime time.Time     `json:"startTime"`
+	Duration  string        `json:"duration"`
+	StatusAt  *time.Time    `json:"statusAt,omitempty"` // when the status the run worked on was fetched
+	Sessions  []reportEntry `json:"sessions"`
+}
+
+// reportEntry is a candidate session of the run and what was decided for it
+type reportEntry struct {
+	SessionID string    `json:"sessionId"`
+	Decision  string    `json:"decision"` // deleted, skipped or failed
+	Pod       string    `json:"pod,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	NodeIP    string    `json:"nodeIp"`
+	Browser   string    `json:"browser,omitempty"`
+	Platform  string    `json:"platform,omitempty"`
+	Start     time.Time `json:"start"`
+	Age       string    `json:"age"` // age the cleaner decided on, clock skew allowance subtracted
+	Error     string    `json:"error,omitempty"`
+}
+
+// reportColumns are the CSV columns, in the order of reportEntry.record
+var reportColumns = []string{"sessionId", "decision", "pod", "namespace", "nodeIp", "browser", "platform", "start", "age", "error"}
+
+func (e reportEntry) record() []string {
+	return []string{e.SessionID, e.Decision, e.Pod, e.Namespace, e.NodeIP, e.Browser, e.Platform,
+		e.Start.Format(time.RFC3339), e.Age, e.Error}
+}
+
+// newRunReport lists every session of the result with its decision
+func newRunReport(result *cleaner.CleanupResult, status *downloader.Status) runReport {
+	report := runReport{
+		StartTime: result.StartTime,
+		Duration:  result.Duration.Round(time.Millisecond).String(),
+		Sessions:  []reportEntry{},
+	}
+	if !status.FetchedAt.IsZero() {
+		report.StatusAt = &status.FetchedAt
+	}
+
+	add := func(session cleaner.SessionInfo, decision string, err error) {
+		entry := reportEntry{
+			SessionID: session.SessionID,
+			Decision:  decision,
+			Pod:       session.PodName,
+			Namespace: session.Namespace,
+			NodeIP:    session.NodeIP,
+			Browser:   session.Browser,
+			Platform:  session.Platform,
+			Start:     session.StartTime,
+			Age:       session.Age.Round(time.Second).String(),
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		report.Sessions = append(report.Sessions, entry)
+	}
+	for _, session := range result.Sessions {
+		add(session, "deleted", nil)
+	}
+	for _, session := range result.Skipped {
+		add(session, "skipped", nil)
+	}
+	for _, session := range result.FailedSessions {
+		add(session, "failed", result.Failed[session.SessionID])
+	}
+	return report
+}
+
+// reportPath returns the file the report is written to. A directory gets a timestamped
+// report named like the status snapshot the run worked on, so the two line up.
+func reportPath(path, format string, status *downloader.Status, runStart time.Time) string {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		if !strings.HasSuffix(path, string(os.PathSeparator)) {
+			return path
+		}
+	}
+
+	taken := status.FetchedAt
+	if taken.IsZero() {
+		taken = runStart
+	}
+	return filepath.Join(path, downloader.SnapshotName(taken, "report."+format))
+}
+
+// writeRunReport writes the report of the run as JSON or CSV, see reportPath for the file
+func writeRunReport(path, format string, result *cleaner.CleanupResult, status *downloader.Status) (string, error) {
+	path = reportPath(path, format, status, result.StartTime)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create report: %w", err)
+	}
+	defer file.Close()
+
+	report := newRunReport(result, status)
+	switch format {
+	case "csv":
+		w := csv.NewWriter(file)
+		_ = w.Write(reportColumns)
+		for _, entry := range report.Sessions {
+			_ = w.Write(entry.record())
+		}
+		w.Flush()
+		err = w.Error()
+	default:
+		enc := json.NewEncoder(file)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, file.Close()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+)
+
+func TestRunReportUsesDecisionAge(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	session := cleaner.SessionInfo{SessionID: "s1", StartTime: start, Age: 115 * time.Minute}
+	result := &cleaner.CleanupResult{
+		StartTime: start.Add(3 * time.Hour),
+		Sessions:  []cleaner.SessionInfo{session},
+		Skipped:   []cleaner.SessionInfo{{SessionID: "s2", StartTime: start, Age: 30 * time.Minute}},
+	}
+
+	report := newRunReport(result, &downloader.Status{})
+	ages := make(map[string]string)
+	for _, entry := range report.Sessions {
+		ages[entry.SessionID] = entry.Age
+	}
+	if ages["s1"] != "1h55m0s" || ages["s2"] != "30m0s" {
+		t.Errorf("report ages = %v, want s1 1h55m0s and s2 30m0s", ages)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Build information, set at build time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.date=..."
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// versionString describes the running build
+func versionString() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", version, commit, date, runtime.Version())
+}
+
+// isVersionFlag reports whether arg asks for the version instead of a subcommand
+func isVersionFlag(arg string) bool {
+	return arg == "-version" || arg == "--version"
+}
+
+// printVersion writes the build information to stdout
+func printVersion() {
+	fmt.Printf("selenium-cleaner %s\n", versionString())
+}
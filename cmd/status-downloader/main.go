@@ -0,0 +1,106 @@
+// cmd/status-downloader/main.go
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/gridconn"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+// status-downloader saves the grid status. Inside the cluster it asks the router service
+// directly over HTTP; elsewhere it runs an ephemeral curl pod in the cluster.
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	log.SetPrefix("[Status Downloader] ")
+
+	namespace := flag.String("namespace", "selenium", "Namespace of the grid router service")
+	service := flag.String("service", "selenium-router", "Grid router service name")
+	port := flag.Int("port", 4444, "Grid router service port")
+	statusPath := flag.String("status-path", gridconn.DefaultStatusPath, "Path of the grid status endpoint, e.g. /status for a Grid 4 router without the /wd/hub prefix")
+	image := flag.String("image", downloader.DefaultCurlImage, "Image of the ephemeral pod running curl")
+	output := flag.String("output", "status.json", "File to write the status to, - for stdout")
+	timeout := flag.Duration("timeout", 2*time.Minute, "Give up fetching the status after this long, deleting the curl pod (0 waits forever)")
+	method := flag.String("method", "auto", "How to reach the grid: http to the service cluster IP, kubectl to run a curl pod, or auto for http when running in the cluster")
+	flag.Parse()
+	if !strings.HasPrefix(*statusPath, "/") {
+		log.Fatalf("-status-path must start with /, got %q", *statusPath)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, *timeout)
+		defer cancelTimeout()
+	}
+
+	if *method == "auto" {
+		*method = "kubectl"
+		if kubernetes.InCluster() {
+			*method = "http"
+		}
+	}
+
+	var data []byte
+	var err error
+	switch *method {
+	case "http":
+		data, err = fetchDirect(ctx, *namespace, *service, *port, *statusPath)
+	case "kubectl":
+		data, err = downloader.FetchViaKubectl(ctx, *namespace, *service, *port, *statusPath, *image)
+	default:
+		log.Fatalf("Unknown -method %q (expected auto, http or kubectl)", *method)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		if *method == "kubectl" {
+			log.Fatalf("Timed out after %v fetching the status; check that the image %s can be pulled: %v", *timeout, *image, err)
+		}
+		log.Fatalf("Timed out after %v fetching the status: %v", *timeout, err)
+	}
+	if err != nil {
+		log.Fatalf("Failed to fetch status: %v", err)
+	}
+
+	if *output == "-" {
+		if _, err := os.Stdout.Write(append(data, '\n')); err != nil {
+			log.Fatalf("Failed to write status: %v", err)
+		}
+		return
+	}
+
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *output, err)
+	}
+	log.Printf("Saved %d bytes to %s", len(data), *output)
+}
+
+// fetchDirect resolves the cluster IP of the router service and fetches the status path from
+// it over HTTP, which only works from inside the cluster network
+func fetchDirect(ctx context.Context, namespace, service string, port int, path string) ([]byte, error) {
+	client, err := kubernetes.NewClient("", "", namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	clusterIP, err := client.GetServiceClusterIP(ctx, namespace, service)
+	if err != nil {
+		return nil, err
+	}
+
+	statusURL := fmt.Sprintf("http://%s%s", net.JoinHostPort(clusterIP, strconv.Itoa(port)), path)
+	log.Printf("Fetching %s", statusURL)
+	return downloader.Fetch(ctx, statusURL, downloader.DefaultOptions())
+}
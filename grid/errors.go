@@ -0,0 +1,20 @@
+package grid
+
+import (
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+// Sentinel errors wrapped by the errors of Run and by the per-session errors of
+// Result.Failed, for use with errors.Is
+var (
+	// ErrStatusDownload: the request for the grid status failed, e.g. the forward is down
+	ErrStatusDownload = downloader.ErrStatusDownload
+	// ErrPodNotFound: no pod matches the node IP or session
+	ErrPodNotFound = kubernetes.ErrPodNotFound
+	// ErrDeletionTimeout: the pod was deleted but did not disappear in time
+	ErrDeletionTimeout = cleaner.ErrDeletionTimeout
+	// ErrSessionTimeout: the cleanup of the session took longer than the session timeout
+	ErrSessionTimeout = cleaner.ErrSessionTimeout
+)
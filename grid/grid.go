@@ -0,0 +1,216 @@
+// Package grid wires the cleaner's components together: the Kubernetes client, the
+// port-forward to the grid, the status download and the cleanup itself. It runs the same
+// components as the selenium-cleaner command and can be embedded in other Go programs.
+package grid
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/gridconn"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultStatusPath is the path of the grid status endpoint when Config.StatusPath is empty
+const DefaultStatusPath = gridconn.DefaultStatusPath
+
+// Forwarder forwards a local port to the grid router
+type Forwarder interface {
+	// Start starts the forward and returns once it is ready
+	Start(ctx context.Context) error
+	// Stop stops the forward
+	Stop()
+	// GetLocalURL rewrites a URL of the grid router to the local end of the forward
+	GetLocalURL(remoteURL string) string
+}
+
+// Download configures the requests for the grid status
+type Download struct {
+	Timeout    time.Duration // Timeout of a single request, 0 means no timeout
+	Attempts   int           // Total attempts including the first one
+	RetryDelay time.Duration // Delay before the first retry, doubled for each following one
+
+	Headers     map[string]string // Extra request headers, e.g. for an auth proxy
+	BearerToken string            // Sent as "Authorization: Bearer <token>" when set
+
+	BasicAuthUser     string // HTTP basic auth user, used when set
+	BasicAuthPassword string // HTTP basic auth password
+
+	InsecureSkipVerify bool   // Skip TLS certificate verification of HTTPS grids
+	CACertFile         string // PEM file with extra CA certificates trusted for HTTPS grids
+
+	DataDir     string        // Directory for status snapshots, next to the executable when empty
+	InMemory    bool          // Parse the response directly without writing snapshots to disk
+	RetainCount int           // Keep at most this many status snapshots, 0 keeps all
+	RetainAge   time.Duration // Remove snapshots older than this, 0 keeps all
+}
+
+// options returns the downloader options for d
+func (d Download) options(httpClient *http.Client, logger *slog.Logger) downloader.Options {
+	return downloader.Options{
+		Timeout:            d.Timeout,
+		Attempts:           d.Attempts,
+		RetryDelay:         d.RetryDelay,
+		Headers:            d.Headers,
+		BearerToken:        d.BearerToken,
+		BasicAuthUser:      d.BasicAuthUser,
+		BasicAuthPassword:  d.BasicAuthPassword,
+		InsecureSkipVerify: d.InsecureSkipVerify,
+		CACertFile:         d.CACertFile,
+		HTTPClient:         httpClient,
+		RetainCount:        d.RetainCount,
+		RetainAge:          d.RetainAge,
+		DataDir:            d.DataDir,
+		InMemory:           d.InMemory,
+		Logger:             logger,
+	}
+}
+
+// Config describes how to reach the grid and what to clean up
+type Config struct {
+	KubeContext string // kubeconfig context, empty for the current one
+	Kubeconfig  string // kubeconfig file, empty for KUBECONFIG or ~/.kube/config
+	Namespace   string // namespace of the grid router
+	Service     string // grid router service
+	Port        int    // grid router port
+	LocalPort   int    // local port of the forward, 0 picks a free one
+	Scheme      string // http or https, http when empty
+	UseKubectl  bool   // forward with kubectl instead of client-go
+
+	Source     string // status or graphql, status when empty
+	StatusPath string // path of the status endpoint, DefaultStatusPath when empty
+	StatusFile string // read the status from this file instead of the grid
+	GridURL    string // base URL of a grid reachable without a port-forward, e.g. http://router:4444
+
+	Download Download
+
+	// Injected dependencies. Without a Clientset a client is built from the kubeconfig or
+	// the in-cluster config.
+	Clientset  k8s.Interface
+	RESTConfig *rest.Config // REST config of Clientset, needed for native port-forwarding
+	HTTPClient *http.Client // client for requests to the grid, built from Download when nil
+	Logger     *slog.Logger // slog.Default() when nil
+
+	// NewForwarder builds the port-forward to the grid, for settings beyond the ones above.
+	// A plain forward to Service is used when nil.
+	NewForwarder func() (Forwarder, error)
+
+	// Cleanup settings
+	MaxAge      time.Duration
+	MaxParallel int
+	DryRun      bool
+}
+
+// connConfig returns the connection settings of cfg
+func (cfg Config) connConfig() gridconn.Config {
+	conn := gridconn.Config{
+		KubeContext: cfg.KubeContext,
+		Kubeconfig:  cfg.Kubeconfig,
+		Namespace:   cfg.Namespace,
+		Service:     cfg.Service,
+		Port:        cfg.Port,
+		LocalPort:   cfg.LocalPort,
+		Scheme:      cfg.Scheme,
+		UseKubectl:  cfg.UseKubectl,
+		Source:      cfg.Source,
+		StatusPath:  cfg.StatusPath,
+		StatusFile:  cfg.StatusFile,
+		GridURL:     cfg.GridURL,
+		Download:    cfg.Download.options(cfg.HTTPClient, cfg.Logger),
+		Clientset:   cfg.Clientset,
+		RESTConfig:  cfg.RESTConfig,
+		HTTPClient:  cfg.HTTPClient,
+		Logger:      cfg.Logger,
+	}
+	if cfg.NewForwarder != nil {
+		conn.NewForwarder = func(*kubernetes.Client) (gridconn.Forwarder, error) {
+			return cfg.NewForwarder()
+		}
+	}
+	return conn
+}
+
+// Session is a grid session seen by Run
+type Session struct {
+	ID        string        // Selenium session ID
+	PodName   string        // Kubernetes pod of the session's node, empty when not resolved
+	Namespace string        // Kubernetes namespace of the pod
+	NodeIP    string        // IP address of the node
+	URI       string        // Node URI
+	Browser   string        // Browser name from the slot stereotype or session capabilities
+	StartTime time.Time     // Session start time
+	Age       time.Duration // Age the cleaner decided on, zero when unknown
+}
+
+// Result summarizes a Run
+type Result struct {
+	Deleted   []Session        // Sessions whose pods were deleted
+	Skipped   []Session        // Sessions that were left alone, including all with DryRun
+	Failed    map[string]error // Cleanup errors keyed by session ID
+	StartTime time.Time        // When the cleanup started
+	Duration  time.Duration    // How long the cleanup took
+}
+
+// newSession converts a session of the cleaner
+func newSession(info cleaner.SessionInfo) Session {
+	return Session{
+		ID:        info.SessionID,
+		PodName:   info.PodName,
+		Namespace: info.Namespace,
+		NodeIP:    info.NodeIP,
+		URI:       info.URI,
+		Browser:   info.Browser,
+		StartTime: info.StartTime,
+		Age:       info.Age,
+	}
+}
+
+// newResult converts the result of the cleaner
+func newResult(r *cleaner.CleanupResult) *Result {
+	result := &Result{
+		Failed:    make(map[string]error, len(r.Failed)),
+		StartTime: r.StartTime,
+		Duration:  r.Duration,
+	}
+	for _, session := range r.Sessions {
+		result.Deleted = append(result.Deleted, newSession(session))
+	}
+	for _, session := range r.Skipped {
+		result.Skipped = append(result.Skipped, newSession(session))
+	}
+	for id, err := range r.Failed {
+		result.Failed[id] = err
+	}
+	return result
+}
+
+// Run opens the grid, fetches its status once and cleans up the sessions older than
+// cfg.MaxAge, closing the grid again before returning. The result is returned along with
+// a cleanup error.
+func Run(ctx context.Context, cfg Config) (*Result, error) {
+	g, err := gridconn.Open(ctx, cfg.connConfig())
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	status, err := g.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cleaner.NewCleaner(g.Client(), cfg.MaxParallel)
+	c.SetLogger(cfg.Logger)
+	c.SetDryRun(cfg.DryRun)
+	r, err := c.CleanPods(ctx, status, cfg.MaxAge)
+	if r == nil {
+		return nil, err
+	}
+	return newResult(r), err
+}
@@ -0,0 +1,119 @@
+package grid
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader/downloadertest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testStatus returns a grid status with one session per node IP, started the given time ago
+func testStatus(sessions map[string]time.Duration) *downloader.Status {
+	status := &downloader.Status{}
+	for ip, started := range sessions {
+		var slot downloader.Slot
+		slot.Stereotype.BrowserName = "chrome"
+		slot.Session.SessionID = "session-" + ip
+		slot.Session.Start = time.Now().Add(-started).Format(time.RFC3339)
+		status.Value.Nodes = append(status.Value.Nodes, downloader.Node{
+			URI:   "http://" + ip + ":5555",
+			Slots: []downloader.Slot{slot},
+		})
+	}
+	return status
+}
+
+func testPod(name, podIP string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "selenium",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-24 * time.Hour)),
+		},
+		Status: corev1.PodStatus{PodIP: podIP},
+	}
+}
+
+// fakeForwarder is a Forwarder to a fake grid
+type fakeForwarder struct {
+	url              string
+	started, stopped bool
+}
+
+func (f *fakeForwarder) Start(ctx context.Context) error { f.started = true; return nil }
+func (f *fakeForwarder) Stop()                           { f.stopped = true }
+func (f *fakeForwarder) GetLocalURL(string) string       { return f.url }
+
+func TestRun(t *testing.T) {
+	grid := downloadertest.NewFakeGrid(testStatus(map[string]time.Duration{
+		"10.0.0.1": 2 * time.Hour,
+		"10.0.0.2": 10 * time.Minute,
+	}))
+	defer grid.Close()
+	clientset := fake.NewSimpleClientset(testPod("node-a", "10.0.0.1"), testPod("node-b", "10.0.0.2"))
+	forwarder := &fakeForwarder{url: grid.URL}
+
+	result, err := Run(context.Background(), Config{
+		Namespace:    "selenium",
+		Clientset:    clientset,
+		Download:     Download{Attempts: 1, InMemory: true},
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		NewForwarder: func() (Forwarder, error) { return forwarder, nil },
+		MaxAge:       time.Hour,
+		MaxParallel:  2,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !forwarder.started || !forwarder.stopped {
+		t.Errorf("forwarder started = %v, stopped = %v, want both", forwarder.started, forwarder.stopped)
+	}
+
+	if len(result.Deleted) != 1 {
+		t.Fatalf("Deleted = %+v, want one session", result.Deleted)
+	}
+	deleted := result.Deleted[0]
+	if deleted.ID != "session-10.0.0.1" || deleted.PodName != "node-a" || deleted.Namespace != "selenium" {
+		t.Errorf("Deleted[0] = %+v, want session-10.0.0.1 on selenium/node-a", deleted)
+	}
+	if deleted.Age < 2*time.Hour-time.Minute {
+		t.Errorf("Deleted[0].Age = %v, want about 2h", deleted.Age)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].ID != "session-10.0.0.2" {
+		t.Errorf("Skipped = %+v, want session-10.0.0.2", result.Skipped)
+	}
+	if len(result.Failed) != 0 {
+		t.Errorf("Failed = %v, want none", result.Failed)
+	}
+
+	pods, err := clientset.CoreV1().Pods("selenium").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].Name != "node-b" {
+		t.Errorf("remaining pods = %v, want [node-b]", pods.Items)
+	}
+}
+
+func TestRunStatusFile(t *testing.T) {
+	result, err := Run(context.Background(), Config{
+		Namespace:  "selenium",
+		StatusFile: "testdata/missing.json",
+		Clientset:  fake.NewSimpleClientset(),
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		NewForwarder: func() (Forwarder, error) {
+			t.Error("NewForwarder() called with a status file")
+			return nil, nil
+		},
+	})
+	if err == nil {
+		t.Fatalf("Run() = %+v, want an error for a missing status file", result)
+	}
+}
@@ -0,0 +1,73 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// AgeSource selects the timestamp session ages are measured from
+type AgeSource string
+
+const (
+	// AgeSourceSession uses the session start reported by the grid
+	AgeSourceSession AgeSource = "session"
+	// AgeSourcePod uses the creation time of the session's pod
+	AgeSourcePod AgeSource = "pod"
+)
+
+// ParseAgeSource validates an age source name
+func ParseAgeSource(name string) (AgeSource, error) {
+	switch source := AgeSource(name); source {
+	case AgeSourceSession, AgeSourcePod:
+		return source, nil
+	}
+	return "", fmt.Errorf("unknown age source %q (expected session or pod)", name)
+}
+
+// SetAgeSource selects what session ages are measured from. With AgeSourcePod the pod of
+// every session is resolved up front and its creation time replaces the session start,
+// for grids reporting unreliable start times.
+func (c *Cleaner) SetAgeSource(source AgeSource) {
+	c.ageSource = source
+}
+
+// usePodAge resolves the pod of the session, records it on the session and replaces the
+// session start with the pod's creation time. The grid's start time is logged alongside
+// for cross-checking.
+func (c *Cleaner) usePodAge(ctx context.Context, session *SessionInfo) error {
+	pod := kubernetes.PodRef{Namespace: session.Namespace, Name: session.PodName}
+	if pod.Name == "" {
+		var err error
+		pod, err = c.getPodName(ctx, *session)
+		if err != nil {
+			return fmt.Errorf("failed to get pod name for IP %s: %w", session.NodeIP, err)
+		}
+	}
+
+	var p *corev1.Pod
+	if c.pods != nil {
+		p, _ = c.pods.pod(pod)
+	}
+	if p == nil {
+		var err error
+		p, err = c.k8sClient.GetPod(ctx, pod.Namespace, pod.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get pod %s: %w", pod, err)
+		}
+	}
+	created := p.CreationTimestamp.Time
+
+	c.logger.Debug("Using the pod creation time as session start",
+		"session_id", session.SessionID, "pod", pod.String(),
+		"session_start", session.StartTime.Format(time.RFC3339), "pod_created", created.Format(time.RFC3339),
+		"difference", session.StartTime.Sub(created).Round(time.Second).String())
+
+	session.PodName = pod.Name
+	session.Namespace = pod.Namespace
+	session.StartTime = created
+	return nil
+}
@@ -0,0 +1,117 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+)
+
+// Verdict is what a cleanup run decides for a session before any pod is looked at
+type Verdict string
+
+const (
+	VerdictExpired         Verdict = "expired"          // Older than its max age, cleaned up
+	VerdictSelected        Verdict = "selected"         // Picked by SetOnly, cleaned up whatever its age
+	VerdictWithinLimit     Verdict = "within-limit"     // Younger than its max age
+	VerdictProtectedAge    Verdict = "protected-age"    // Younger than the min protected age
+	VerdictNodeUnavailable Verdict = "node-unavailable" // Node is not UP and draining nodes are skipped
+	VerdictNotSelected     Verdict = "not-selected"     // Left out by SetOnly
+	VerdictUnknownAge      Verdict = "unknown-age"      // Pod age could not be determined
+)
+
+// Cleaned reports whether the session is cleaned up, pod protection and debouncing aside
+func (v Verdict) Cleaned() bool {
+	return v == VerdictExpired || v == VerdictSelected
+}
+
+// evaluate decides the verdict of the session and returns its age and max age. With the pod
+// age source the session start is replaced by the pod's creation; a session cleaned up for
+// exceeding its max age gets MaxAge set. The age is recorded on the session even for
+// verdicts that do not depend on it.
+func (c *Cleaner) evaluate(ctx context.Context, session *SessionInfo, maxAge time.Duration) (Verdict, time.Duration, time.Duration, error) {
+	session.Age = c.sessionAge(*session)
+	targeted := c.targeted()
+	if targeted && !c.selected(*session) {
+		return VerdictNotSelected, 0, 0, nil
+	}
+	if c.skipDraining && !targeted && !session.nodeUp() {
+		return VerdictNodeUnavailable, 0, 0, nil
+	}
+	if c.ageSource == AgeSourcePod {
+		if err := c.usePodAge(ctx, session); err != nil {
+			session.Age = 0
+			return VerdictUnknownAge, 0, 0, err
+		}
+		session.Age = c.sessionAge(*session)
+	}
+
+	age := session.Age
+	limit := c.maxAgeFor(*session, maxAge)
+	switch {
+	case age < c.minProtectedAge:
+		return VerdictProtectedAge, age, limit, nil
+	case targeted:
+		if age > limit {
+			session.MaxAge = limit
+		}
+		return VerdictSelected, age, limit, nil
+	case age <= limit:
+		return VerdictWithinLimit, age, limit, nil
+	}
+	session.MaxAge = limit
+	return VerdictExpired, age, limit, nil
+}
+
+// SessionAnalysis is the verdict on one session of the status
+type SessionAnalysis struct {
+	Session SessionInfo
+	Age     time.Duration // Age the verdict is based on, clock skew allowance subtracted
+	MaxAge  time.Duration // Max age that applies to the session
+	Verdict Verdict
+	Err     error // Set for VerdictUnknownAge
+}
+
+// Analysis is what a cleanup run at Time would do with a status
+type Analysis struct {
+	Time     time.Time
+	Nodes    NodeSummary
+	Sessions []SessionAnalysis
+	Cleaned  int   // Sessions whose verdict cleans them up
+	Blocked  error // Safety valve error that would stop the run, nil if it would go ahead
+}
+
+// Analyze evaluates every session of the status against maxAge and the cleaner's rules
+// like CleanPods does, without resolving, quitting or deleting any pod. Only the pod age
+// source reads pods.
+func (c *Cleaner) Analyze(ctx context.Context, status *downloader.Status, maxAge time.Duration) (*Analysis, error) {
+	sessions, err := c.parseSessionInfo(status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse session info: %w", err)
+	}
+
+	c.pods = newPodIndex(c.k8sClient, c.sessionLabel)
+	defer func() { c.pods = nil }()
+
+	analysis := &Analysis{Time: c.now(), Nodes: summarizeNodes(status)}
+	for _, session := range sessions {
+		verdict, age, limit, err := c.evaluate(ctx, &session, maxAge)
+		if verdict == VerdictNotSelected || verdict == VerdictNodeUnavailable {
+			// Decided before the age, which is still worth showing
+			age, limit = session.Age, c.maxAgeFor(session, maxAge)
+		}
+		analysis.Sessions = append(analysis.Sessions, SessionAnalysis{
+			Session: session,
+			Age:     age,
+			MaxAge:  limit,
+			Verdict: verdict,
+			Err:     err,
+		})
+		if verdict.Cleaned() {
+			analysis.Cleaned++
+		}
+	}
+	analysis.Blocked = c.safetyValveError(analysis.Cleaned, len(sessions))
+	return analysis, nil
+}
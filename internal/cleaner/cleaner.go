@@ -3,15 +3,21 @@ package cleaner
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
+	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
 	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
@@ -21,195 +27,760 @@ type SessionInfo struct {
     StartTime time.Time // Session start time
     SessionID string    // Selenium session ID
     PodName   string    // Kubernetes pod name
+    Namespace string    // Kubernetes namespace of the pod
     URI       string    // Node URI
+    HostID    string    // Host ID of the slot, the pod name in many deployments
+    Browser   string    // Browser name from the slot stereotype or session capabilities
+    Platform  string    // Platform name from the slot stereotype or session capabilities
+
+    NodeAvailability string        // Availability reported for the node (UP, DRAINING, DOWN), may be empty
+    MaxAge           time.Duration // Max age the session exceeded, zero when it was cleaned on request
+    Age              time.Duration // Age the cleaner decided on, clock skew allowance subtracted, zero when unknown
 }
 
+// defaultDeletionTimeout is how long to wait for a deleted pod to disappear
+const defaultDeletionTimeout = 2 * time.Minute
+
 // Cleaner handles the cleaning of old grid sessions
 type Cleaner struct {
-    k8sClient   *kubernetes.Client
+    k8sClient   PodManager
     maxParallel int
-    errors      []error
-    mutex       sync.Mutex
+    stats       CleanupStats
+    onEvent     func(CleanupEvent) // progress callback, may be nil
+    excludeIPs  []ipRule           // node IPs whose sessions are never cleaned
+    deletions   *deletionLog // recently deleted targets, nil when debouncing is disabled
+    dryRun      bool         // resolve and report pods without deleting them
+
+    pollDeletions atomic.Bool // confirm deletions by polling, set once watching pods was forbidden
+
+    excludeBrowsers map[string]bool // lower-case browser names whose sessions are never cleaned
+
+    // Surgical runs cleaning up only these sessions, whatever their age
+    onlyIPs      []ipRule
+    onlySessions map[string]bool
+
+    // Safety valve against deleting most of the grid in one run, 0 disables a limit
+    maxDeleteFraction float64 // max share of all sessions
+    maxDeleteCount    int     // max number of sessions
+
+    skipDraining     bool // leave sessions alone on nodes that are not UP
+    resolveHostnames bool // find the pods of nodes registered by DNS name
+
+    protectAnnotation string // pods annotated with this key set to "true" are never deleted
+    sessionLabel      string // pod label carrying the session ID, empty scans container env vars
+
+    deletionTimeout time.Duration            // how long to wait for deletion confirmation
+    sessionTimeout  time.Duration            // bound of a single session cleanup, 0 for none
+    browserMaxAge   map[string]time.Duration // per-browser max age overrides, keyed by lower-case browser name
+    minProtectedAge time.Duration            // sessions younger than this are never cleaned, whatever the max age
+    clockSkew       time.Duration            // allowance subtracted from session ages
+    now             func() time.Time         // current time ages are measured against
+    gridClock       bool                     // convert grid timestamps with the clock offset of the status
+    ageSource       AgeSource                // what session ages are measured from
+    pods            *podIndex                // pods of the current CleanPods run, nil outside of it
+    retryPolicy     RetryPolicy              // retries of transient pod deletion errors
+    deleteLimiter   *rate.Limiter            // paces delete requests, nil when unlimited
+    gracePeriod     *int64                   // deletion grace period in seconds, nil keeps the pod's own
+    forceAfter      time.Duration            // force delete pods still present after this long, 0 disables
+    shutdownGrace   time.Duration            // how long in-flight cleanups outlive a cancelled context
+    forceFinalizers bool                     // also strip finalizers when force deleting
+    cordon          bool                     // cordon the pod's node before deleting the pod
+    uncordon        bool                     // uncordon nodes cordoned by the cleaner afterwards
+    cordons         cordonedNodes            // nodes held by in-flight cleanups
+    emitEvents      bool                     // record a Kubernetes event for every deleted pod
+    logger          *slog.Logger
+
+    // Graceful quit through the grid before deleting a pod
+    gracefulQuit bool
+    gridURL      string
+    httpClient   *http.Client
+    quitTimeout  time.Duration
+    quitGrace    time.Duration
+    quitViaNode  bool        // quit on the session's node rather than the router
+    nodeForward  NodeForward // reaches nodes for quitViaNode, nil sends to the node URI as is
 }
 
-// NewCleaner creates a new instance of Cleaner
-func NewCleaner(k8sClient *kubernetes.Client, maxParallel int) *Cleaner {
+// NewCleaner creates a new instance of Cleaner working on the pods of k8sClient
+func NewCleaner(k8sClient PodManager, maxParallel int) *Cleaner {
     if maxParallel <= 0 {
         maxParallel = 10 // default value
     }
 
-    return &Cleaner{
+    c := &Cleaner{
         k8sClient:   k8sClient,
         maxParallel: maxParallel,
-        errors:      make([]error, 0),
+
+        deletionTimeout: defaultDeletionTimeout,
+        sessionTimeout:  defaultSessionTimeout,
+        now:             time.Now,
+        logger:          slog.Default(),
+    }
+    c.SetRetryPolicy(DefaultRetryPolicy())
+
+    return c
+}
+
+// SetLogger sets the logger used for progress and diagnostics, slog.Default() when nil
+func (c *Cleaner) SetLogger(logger *slog.Logger) {
+    if logger == nil {
+        logger = slog.Default()
+    }
+    c.logger = logger
+}
+
+// Stats returns the counters accumulated over all CleanPods runs
+func (c *Cleaner) Stats() *CleanupStats {
+    return &c.stats
+}
+
+// SetDeletionTimeout sets how long to wait for a deleted pod to disappear.
+// Non-positive values restore the default of 2 minutes.
+func (c *Cleaner) SetDeletionTimeout(timeout time.Duration) {
+    if timeout <= 0 {
+        timeout = defaultDeletionTimeout
+    }
+    c.deletionTimeout = timeout
+}
+
+// SetSessionLabel makes session ID lookups select pods by the given label, whose value is
+// the session ID, before falling back to scanning the container environment
+func (c *Cleaner) SetSessionLabel(labelKey string) {
+    c.sessionLabel = labelKey
+}
+
+// SetDeleteGracePeriod overrides the termination grace period of deleted pods. A negative
+// period keeps the pod's own terminationGracePeriodSeconds, 0 deletes immediately.
+func (c *Cleaner) SetDeleteGracePeriod(period time.Duration) {
+    if period < 0 {
+        c.gracePeriod = nil
+        return
+    }
+    seconds := int64(period.Seconds())
+    c.gracePeriod = &seconds
+}
+
+// SetMinProtectedAge sets a hard floor below which sessions are never cleaned up, whatever
+// the max age or browser overrides say. It guards against clock skew and mis-set overrides.
+func (c *Cleaner) SetMinProtectedAge(age time.Duration) {
+    c.minProtectedAge = age
+}
+
+// SetClockSkew makes session ages tolerate a clock difference between the grid and the
+// cleaner. allowance is subtracted from every computed age. With gridClock, start times are
+// also shifted by the clock offset measured from the status response, so ages are computed
+// as if on the grid's clock.
+func (c *Cleaner) SetClockSkew(allowance time.Duration, gridClock bool) {
+    c.clockSkew = allowance
+    c.gridClock = gridClock
+}
+
+// SetClock overrides the current time session and pod ages and the delete debounce window
+// are measured against, e.g. to see what a run at another time would clean up. Timeouts and
+// delays keep the wall clock.
+// nil restores time.Now.
+func (c *Cleaner) SetClock(now func() time.Time) {
+    if now == nil {
+        now = time.Now
+    }
+    c.now = now
+}
+
+// sessionAge returns the age of the session minus the clock skew allowance
+func (c *Cleaner) sessionAge(session SessionInfo) time.Duration {
+    return c.now().Sub(session.StartTime) - c.clockSkew
+}
+
+// SetBrowserMaxAges overrides the max age for sessions of the given browsers.
+// Browser names are matched case-insensitively; other browsers use the CleanPods max age.
+func (c *Cleaner) SetBrowserMaxAges(maxAges map[string]time.Duration) {
+    c.browserMaxAge = make(map[string]time.Duration, len(maxAges))
+    for browser, maxAge := range maxAges {
+        c.browserMaxAge[strings.ToLower(browser)] = maxAge
+    }
+}
+
+// maxAgeFor returns the max age that applies to the session
+func (c *Cleaner) maxAgeFor(session SessionInfo, defaultMaxAge time.Duration) time.Duration {
+    if maxAge, ok := c.browserMaxAge[strings.ToLower(session.Browser)]; ok {
+        return maxAge
+    }
+    return defaultMaxAge
+}
+
+// SetProtectAnnotation sets the annotation key that protects a pod from deletion when set to "true".
+// An empty key disables the check.
+func (c *Cleaner) SetProtectAnnotation(key string) {
+    c.protectAnnotation = key
+}
+
+// isProtected reports whether the pod carries the protect annotation
+func (c *Cleaner) isProtected(ctx context.Context, namespace, podName string) (bool, error) {
+    if c.protectAnnotation == "" {
+        return false, nil
+    }
+
+    annotations, err := c.k8sClient.GetPodAnnotations(ctx, namespace, podName)
+    if err != nil {
+        return false, err
+    }
+    return annotations[c.protectAnnotation] == "true", nil
+}
+
+// SetDryRun toggles dry-run mode, in which matching pods are resolved and reported but never deleted
+func (c *Cleaner) SetDryRun(dryRun bool) {
+    c.dryRun = dryRun
+}
+
+// SetDeleteDebounce makes the cleaner refuse to delete a pod or session that was already
+// deleted within window. Deletions are remembered across runs in the file at path.
+func (c *Cleaner) SetDeleteDebounce(window time.Duration, path string) error {
+    if window <= 0 {
+        c.deletions = nil
+        return nil
+    }
+
+    deletions, err := loadDeletionLog(path, window, c.now())
+    if err != nil {
+        return err
     }
+    c.deletions = deletions
+    return nil
+}
+
+// SetSkipDraining makes the cleaner leave sessions alone when their node is not UP,
+// e.g. while it is draining and the grid still expects its sessions to finish
+func (c *Cleaner) SetSkipDraining(skip bool) {
+    c.skipDraining = skip
+}
+
+// nodeUp reports whether the session's node is available. Grids that do not report
+// availability are treated as UP.
+func (s *SessionInfo) nodeUp() bool {
+    return s.NodeAvailability == "" || strings.EqualFold(s.NodeAvailability, "UP")
 }
 
-// addError thread-safely adds an error to the error list
-func (c *Cleaner) addError(err error) {
-    c.mutex.Lock()
-    defer c.mutex.Unlock()
-    c.errors = append(c.errors, err)
+// startTimeLayouts are the timestamp formats used by the grid for session start times
+var startTimeLayouts = []string{time.RFC3339Nano, time.RFC3339}
+
+// parseStartTime returns the first of the given timestamps that parses, trying the slot's
+// lastStarted before the session's own start time
+func parseStartTime(candidates ...string) (time.Time, bool) {
+    for _, candidate := range candidates {
+        if candidate == "" {
+            continue
+        }
+        for _, layout := range startTimeLayouts {
+            if t, err := time.Parse(layout, candidate); err == nil {
+                return t, true
+            }
+        }
+    }
+    return time.Time{}, false
+}
+
+// nodeIPFromURI returns the host of a node URI, empty when the URI names no usable host.
+// IPv6 hosts lose their brackets and are returned in canonical form, e.g. fd00::1 for
+// http://[fd00:0::1]:5555.
+func nodeIPFromURI(uri string) (string, error) {
+    nodeURL, err := url.Parse(uri)
+    if err != nil {
+        return "", fmt.Errorf("failed to parse node URI %s: %w", uri, err)
+    }
+
+    // Hostname strips the port, if any, and the brackets of IPv6 literals
+    nodeIP := nodeURL.Hostname()
+    if nodeIP == "localhost" {
+        return "", nil
+    }
+    return kubernetes.CanonicalIP(nodeIP), nil
 }
 
 // parseSessionInfo extracts session information from grid status
 func (c *Cleaner) parseSessionInfo(status *downloader.Status) ([]SessionInfo, error) {
     var sessions []SessionInfo
+    excludedBrowsers := make(map[string]int)
+
+    // Start times are in the grid's clock; shifting them by its offset puts them in ours
+    var offset time.Duration
+    if c.gridClock {
+        offset = status.ClockOffset
+    }
 
     for _, node := range status.Value.Nodes {
-        nodeURL, err := url.Parse(node.URI)
+        nodeIP, err := nodeIPFromURI(node.URI)
         if err != nil {
-            return nil, fmt.Errorf("failed to parse node URI %s: %w", node.URI, err)
+            return nil, err
         }
-
-        nodeIP := strings.Split(nodeURL.Host, ":")[0]
-        if nodeIP == "" || nodeIP == "localhost" {
-            log.Printf("Warning: Invalid node IP from URI %s", node.URI)
+        if nodeIP == "" {
+            c.logger.Warn("Invalid node IP from URI", "uri", node.URI)
+            continue
+        }
+        if isHostname(nodeIP) && !c.resolveHostnames {
+            c.logger.Warn("Node URI has a hostname instead of an IP, skipping its sessions unless hostname resolution is enabled",
+                "uri", node.URI)
             continue
         }
 
+        excludeRule, excluded := c.excludedBy(nodeIP)
+
         for _, slot := range node.Slots {
             if slot.Session.SessionID == "" {
                 continue
             }
 
-            startTime, err := time.Parse(time.RFC3339Nano, slot.LastStarted)
-            if err != nil {
-                log.Printf("Warning: Could not parse start time for session %s: %v",
-                    slot.Session.SessionID, err)
+            if excluded {
+                c.logger.Info("Session is excluded, skipping",
+                    "session_id", slot.Session.SessionID, "node_ip", nodeIP, "rule", excludeRule)
+                continue
+            }
+
+            startTime, ok := parseStartTime(slot.LastStarted, slot.Session.Start)
+            if !ok {
+                c.logger.Warn("Could not parse session start time",
+                    "session_id", slot.Session.SessionID, "last_started", slot.LastStarted, "session_start", slot.Session.Start)
+                continue
+            }
+
+            browser := slot.Stereotype.BrowserName
+            if browser == "" {
+                browser = slot.Session.Capabilities.BrowserName
+            }
+            platform := slot.Stereotype.PlatformName
+            if platform == "" {
+                platform = slot.Session.Capabilities.PlatformName
+            }
+
+            if c.excludeBrowsers[strings.ToLower(browser)] {
+                c.logger.Debug("Session browser is excluded, skipping",
+                    "session_id", slot.Session.SessionID, "node_ip", nodeIP, "browser", browser)
+                excludedBrowsers[strings.ToLower(browser)]++
                 continue
             }
 
             sessions = append(sessions, SessionInfo{
                 NodeIP:    nodeIP,
-                StartTime: startTime,
+                StartTime: startTime.Add(-offset),
                 SessionID: slot.Session.SessionID,
                 URI:       node.URI,
+                HostID:    slot.ID.HostID,
+                Browser:   browser,
+                Platform:  platform,
+
+                NodeAvailability: node.Availability,
             })
         }
     }
 
+    for _, browser := range slices.Sorted(maps.Keys(excludedBrowsers)) {
+        c.logger.Info("Sessions of excluded browser skipped", "browser", browser, "count", excludedBrowsers[browser])
+    }
+
     return sessions, nil
 }
 
-// getPodName retrieves the pod name for a given node IP
-func (c *Cleaner) getPodName(ctx context.Context, nodeIP string) (string, error) {
-    pods, err := c.k8sClient.GetPodsByIP(ctx, nodeIP)
+// getPodName retrieves the pod for a session from its node IP, or from the host ID of
+// its slot when no pod has the IP. When several pods match, the one carrying the session
+// ID is preferred over the first match.
+func (c *Cleaner) getPodName(ctx context.Context, session SessionInfo) (kubernetes.PodRef, error) {
+    var pods []kubernetes.PodRef
+    var err error
+    strategy := "ip"
+    if isHostname(session.NodeIP) {
+        strategy = "hostname"
+        pods, err = c.podsByHostname(ctx, session.NodeIP)
+    } else if c.pods != nil {
+        pods, err = c.pods.podsByIP(ctx, session.NodeIP)
+    } else {
+        pods, err = c.k8sClient.GetPodsByIP(ctx, session.NodeIP)
+    }
     if err != nil {
-        return "", fmt.Errorf("failed to get pods by IP %s: %w", nodeIP, err)
+        return kubernetes.PodRef{}, fmt.Errorf("failed to get pods of node %s: %w", session.NodeIP, err)
+    }
+
+    if len(pods) == 0 && session.HostID != "" {
+        strategy = "hostId"
+        pods, err = c.podsByHostID(ctx, session.HostID)
+        if err != nil {
+            return kubernetes.PodRef{}, fmt.Errorf("failed to get pods of host ID %s: %w", session.HostID, err)
+        }
+        if len(pods) > 0 {
+            c.logger.Info("No pod has the node IP, resolved the pod by the slot's host ID",
+                "session_id", session.SessionID, "node_ip", session.NodeIP, "host_id", session.HostID)
+        }
     }
 
     if len(pods) == 0 {
-        return "", fmt.Errorf("no pod found for IP %s", nodeIP)
+        return kubernetes.PodRef{}, fmt.Errorf("%w for IP %s", kubernetes.ErrPodNotFound, session.NodeIP)
+    }
+
+    c.logger.Debug("Resolved pods of session",
+        "session_id", session.SessionID, "node_ip", session.NodeIP, "strategy", strategy, "pods", len(pods))
+    if len(pods) == 1 {
+        return pods[0], nil
+    }
+
+    names := make([]string, 0, len(pods))
+    for _, pod := range pods {
+        names = append(names, pod.String())
+    }
+    c.logger.Warn("Several pods share the node IP, matching by session ID",
+        "session_id", session.SessionID, "node_ip", session.NodeIP, "strategy", strategy, "pods", strings.Join(names, ", "))
+
+    match, err := c.podNameBySessionID(ctx, pods, session.SessionID)
+    if err == nil {
+        for _, pod := range pods {
+            if pod == match {
+                return match, nil
+            }
+        }
     }
 
+    c.logger.Warn("No pod on the node IP matches the session, falling back to the first one",
+        "session_id", session.SessionID, "node_ip", session.NodeIP, "pod", pods[0].String())
     return pods[0], nil
 }
 
-// waitForPodDeletion waits for the pod to be deleted
-func (c *Cleaner) waitForPodDeletion(ctx context.Context, podName string) error {
-    watcher, err := c.k8sClient.WatchPod(ctx, podName)
+// podNameBySessionID finds the pod running the session, by label in the namespaces of the
+// candidate pods when a session label is configured and by scanning the container
+// environment otherwise
+func (c *Cleaner) podNameBySessionID(ctx context.Context, candidates []kubernetes.PodRef, sessionID string) (kubernetes.PodRef, error) {
+    if c.pods != nil {
+        if pod, ok := c.pods.session(sessionID); ok {
+            return pod, nil
+        }
+    }
+    if c.sessionLabel == "" {
+        return c.k8sClient.GetPodNameBySessionID(ctx, sessionID)
+    }
+
+    seen := make(map[string]bool)
+    for _, candidate := range candidates {
+        if seen[candidate.Namespace] {
+            continue
+        }
+        seen[candidate.Namespace] = true
+
+        name, err := c.k8sClient.GetPodNameBySessionIDLabeled(ctx, candidate.Namespace, c.sessionLabel, sessionID)
+        if err == nil {
+            return kubernetes.PodRef{Namespace: candidate.Namespace, Name: name}, nil
+        }
+    }
+    return kubernetes.PodRef{}, fmt.Errorf("%w for session %s", kubernetes.ErrPodNotFound, sessionID)
+}
+
+// waitForPodDeletion waits up to timeout for the pod to be deleted. It watches the pod, or
+// polls it where watching pods is forbidden.
+func (c *Cleaner) waitForPodDeletion(ctx context.Context, namespace, podName string, timeout time.Duration) error {
+    if c.pollDeletions.Load() {
+        return c.pollPodDeletion(ctx, namespace, podName, timeout)
+    }
+
+    watcher, err := c.k8sClient.WatchPod(ctx, namespace, podName)
+    if errors.Is(err, kubernetes.ErrPodDeleted) {
+        return nil
+    }
+    if c.watchForbidden(err) {
+        return c.pollPodDeletion(ctx, namespace, podName, timeout)
+    }
     if err != nil {
         return fmt.Errorf("failed to create pod watcher: %w", err)
     }
-    defer watcher.Stop()
+    defer func() {
+        if watcher != nil {
+            watcher.Stop()
+        }
+    }()
 
-    timeout := time.After(2 * time.Minute)
+    start := time.Now()
+    lastEvent := "none"
+    expired := time.After(timeout)
     for {
         select {
         case <-ctx.Done():
             return ctx.Err()
-        case <-timeout:
-            return fmt.Errorf("timeout waiting for pod %s deletion", podName)
+        case <-expired:
+            return fmt.Errorf("timeout waiting for pod %s deletion after %v (last watch event: %s): %w",
+                podName, time.Since(start).Round(time.Second), lastEvent, ErrDeletionTimeout)
         case event, ok := <-watcher.ResultChan():
             if !ok {
                 return fmt.Errorf("watch channel closed unexpectedly")
             }
+            lastEvent = string(event.Type)
             switch event.Type {
             case watch.Deleted:
                 return nil
             case watch.Error:
-                return fmt.Errorf("error watching pod %s: %v", podName, event.Object)
+                if !kubernetes.IsWatchExpired(event) {
+                    return fmt.Errorf("error watching pod %s: %v", podName, event.Object)
+                }
+                // The resource version expired, restart the watch from the pod's current state
+                watcher.Stop()
+                watcher, err = c.k8sClient.WatchPod(ctx, namespace, podName)
+                if errors.Is(err, kubernetes.ErrPodDeleted) {
+                    return nil
+                }
+                if c.watchForbidden(err) {
+                    return c.pollPodDeletion(ctx, namespace, podName, timeout-time.Since(start))
+                }
+                if err != nil {
+                    return fmt.Errorf("failed to restart pod watcher: %w", err)
+                }
             }
         }
     }
 }
 
-// cleanupSession handles the cleanup of a single session
-func (c *Cleaner) cleanupSession(ctx context.Context, session SessionInfo) error {
-    logger := log.Default()
-    logger.Printf("Processing session %s on node %s", session.SessionID, session.NodeIP)
+// cleanupSession handles the cleanup of a single session. Unless the session already names
+// its pod, the pod is resolved from the node IP and recorded on the session. It reports
+// whether the pod was actually deleted.
+func (c *Cleaner) cleanupSession(ctx context.Context, session *SessionInfo) (bool, error) {
+    logger := c.logger.With("session_id", session.SessionID, "node_ip", session.NodeIP)
+    logger.Info("Processing session")
+
+    if c.deletions != nil {
+        if deletedAt, ok := c.deletions.recent(sessionKey(session.SessionID), c.now()); ok {
+            logger.Info("Session was already cleaned, skipping (debounce)",
+                "deleted_at", deletedAt.Format(time.RFC3339))
+            return false, nil
+        }
+    }
 
-    podName, err := c.getPodName(ctx, session.NodeIP)
+    pod := kubernetes.PodRef{Namespace: session.Namespace, Name: session.PodName}
+    if pod.Name == "" {
+        var err error
+        pod, err = c.getPodName(ctx, *session)
+        if err != nil {
+            return false, fmt.Errorf("failed to get pod name for IP %s: %w", session.NodeIP, err)
+        }
+    }
+    podName := pod.Name
+    session.PodName = pod.Name
+    session.Namespace = pod.Namespace
+    logger = logger.With("pod", podName, "namespace", pod.Namespace)
+
+    protected, err := c.isProtected(ctx, session.Namespace, podName)
     if err != nil {
-        return fmt.Errorf("failed to get pod name for IP %s: %w", session.NodeIP, err)
+        return false, fmt.Errorf("failed to check protection of pod %s: %w", podName, err)
+    }
+    if protected {
+        logger.Info("Pod is protected, skipping session", "annotation", c.protectAnnotation)
+        return false, nil
+    }
+
+    if c.deletions != nil {
+        if deletedAt, ok := c.deletions.recent(podKey(pod), c.now()); ok {
+            logger.Info("Pod was already deleted, skipping (debounce)",
+                "deleted_at", deletedAt.Format(time.RFC3339))
+            return false, nil
+        }
+    }
+
+    if c.dryRun {
+        logger.Info("Dry run: would delete pod",
+            "age", session.Age.Round(time.Second).String())
+        return false, nil
+    }
+
+    if c.gracefulQuit {
+        c.gracefulQuitSession(ctx, *session)
     }
 
+    if c.cordon {
+        if node := c.cordonPodNode(ctx, logger, session.Namespace, podName); node != "" {
+            defer c.releasePodNode(ctx, logger, node)
+        }
+    }
+
+    c.emit(PhaseDeleting, *session, nil)
+
     // Delete the pod
-    if err := c.k8sClient.DeletePod(ctx, podName); err != nil {
-        return fmt.Errorf("failed to delete pod %s: %w", podName, err)
+    gone, err := c.deletePodWithRetry(ctx, session.Namespace, podName)
+    if err != nil {
+        return false, fmt.Errorf("failed to delete pod %s: %w", podName, err)
+    }
+
+    // Remember the deletion even if confirmation fails below, the delete was issued
+    if c.deletions != nil {
+        c.deletions.record(c.now(), podKey(pod), sessionKey(session.SessionID))
+    }
+    if c.emitEvents {
+        c.recordCleanedEvent(ctx, logger, *session)
     }
 
     // Wait for pod deletion confirmation
-    if err := c.waitForPodDeletion(ctx, podName); err != nil {
-        return fmt.Errorf("failed to confirm pod %s deletion: %w", podName, err)
+    if !gone {
+        if err := c.confirmPodDeletion(ctx, session.Namespace, podName); err != nil {
+            return false, fmt.Errorf("failed to confirm pod %s deletion: %w", podName, err)
+        }
     }
 
-    logger.Printf("Successfully deleted pod %s for session %s", podName, session.SessionID)
-    return nil
+    logger.Info("Successfully deleted pod")
+    return true, nil
 }
 
-// CleanPods identifies and terminates Selenium Grid pods that have been running longer than the specified duration
-func (c *Cleaner) CleanPods(ctx context.Context, status *downloader.Status, maxAge time.Duration) error {
-    log.Printf("Starting pod cleanup with max age of %v", maxAge)
+// CleanPods identifies and terminates Selenium Grid pods that have been running longer than the specified duration.
+// The returned result lists what happened to every session; the error is non-nil only when at least
+// one session could not be cleaned up. A run stopped by the safety valve deletes nothing and is
+// reported through result.Refused, not the error.
+func (c *Cleaner) CleanPods(ctx context.Context, status *downloader.Status, maxAge time.Duration) (*CleanupResult, error) {
+    c.logger.Info("Starting pod cleanup", "max_age", maxAge.String())
+    result := newCleanupResult()
+    defer func() {
+        result.Duration = time.Since(result.StartTime)
+        c.stats.finishRun(result.Duration)
+    }()
 
     sessions, err := c.parseSessionInfo(status)
     if err != nil {
-        return fmt.Errorf("failed to parse session info: %w", err)
+        return result, fmt.Errorf("failed to parse session info: %w", err)
+    }
+    result.Nodes = summarizeNodes(status)
+    c.logNodeSummary(result.Nodes)
+
+    if status.ClockOffset.Abs() > c.clockSkew && status.ClockOffset.Abs() > time.Second {
+        c.logger.Warn("Grid clock differs from the local clock",
+            "offset", status.ClockOffset.String(), "clock_skew", c.clockSkew.String(), "grid_clock", c.gridClock)
     }
 
     sessionCount := len(sessions)
-    log.Printf("Found %d active sessions", sessionCount)
+    c.stats.addSeen(sessionCount)
+    c.logger.Info("Found active sessions", "count", sessionCount)
 
     if sessionCount == 0 {
-        log.Println("No sessions to clean up")
-        return nil
+        c.logger.Info("No sessions to clean up")
+        return result, nil
     }
 
-    var wg sync.WaitGroup
-    sem := make(chan struct{}, c.maxParallel)
+    // Pods are listed once for the whole run, on the first lookup
+    c.pods = newPodIndex(c.k8sClient, c.sessionLabel)
+    defer func() { c.pods = nil }()
 
     for _, session := range sessions {
-        age := time.Since(session.StartTime)
-        if age <= maxAge {
-            log.Printf("Session %s age %v is within limit, skipping",
-                session.SessionID, age.Round(time.Second))
+        c.emit(PhaseParsed, session, nil)
+    }
+
+    targeted := c.targeted()
+    skippedUnavailable := 0
+    var candidates []SessionInfo
+    for _, session := range sessions {
+        verdict, age, limit, err := c.evaluate(ctx, &session, maxAge)
+        switch verdict {
+        case VerdictNotSelected:
+            c.logger.Debug("Session is not selected for this run, skipping",
+                "session_id", session.SessionID, "node_ip", session.NodeIP)
+        case VerdictNodeUnavailable:
+            c.logger.Info("Session node is not available, skipping",
+                "session_id", session.SessionID, "node_ip", session.NodeIP, "availability", session.NodeAvailability)
+            skippedUnavailable++
+        case VerdictUnknownAge:
+            c.logger.Error("Failed to determine the pod age of session", "session_id", session.SessionID, "error", err)
+            result.addFailed(session, err)
+            c.stats.addFailure()
+            c.emit(PhaseFailed, session, err)
+            continue
+        case VerdictProtectedAge:
+            c.logger.Info("Session is younger than the protected age, skipping",
+                "session_id", session.SessionID, "age", age.Round(time.Second).String(), "min_protected_age", c.minProtectedAge.String())
+        case VerdictWithinLimit:
+            c.logger.Debug("Session age is within limit, skipping",
+                "session_id", session.SessionID, "age", age.Round(time.Second).String())
+        case VerdictSelected:
+            c.logger.Info("Session is selected for this run, cleaning it up regardless of its max age",
+                "session_id", session.SessionID, "node_ip", session.NodeIP, "browser", session.Browser,
+                "age", age.Round(time.Second).String(), "max_age", limit.String())
+            candidates = append(candidates, session)
+            continue
+        case VerdictExpired:
+            c.logger.Info("Session exceeded its max age",
+                "session_id", session.SessionID, "node_ip", session.NodeIP, "browser", session.Browser,
+                "age", age.Round(time.Second).String(), "max_age", limit.String())
+            c.stats.addExpired()
+            candidates = append(candidates, session)
             continue
         }
+        result.addSkipped(session)
+        c.emit(PhaseSkipped, session, nil)
+    }
 
-        log.Printf("Session %s has been running for %v, exceeding max age of %v",
-            session.SessionID, age.Round(time.Second), maxAge)
+    if targeted {
+        c.logger.Info("Sessions selected for this run", "selected", len(candidates), "total", sessionCount)
+    } else {
+        c.logger.Info("Sessions exceeded their max age", "expired", len(candidates), "total", sessionCount)
+    }
+    if skippedUnavailable > 0 {
+        c.logger.Info("Skipped sessions on draining or unavailable nodes", "count", skippedUnavailable)
+    }
 
-        wg.Add(1)
-        sem <- struct{}{}
+    // Nothing is deleted when the run would remove an implausible share of the grid
+    if err := c.checkSafetyValve(len(candidates), sessionCount); err != nil {
+        c.logger.Error("Safety valve stopped the cleanup, no pods were deleted", "error", err)
+        for _, session := range candidates {
+            result.addSkipped(session)
+            c.emit(PhaseSkipped, session, nil)
+        }
+        result.Refused = err
+        return result, nil
+    }
 
+    var wg sync.WaitGroup
+    sem := make(chan struct{}, c.maxParallel)
+
+    // In-flight cleanups get the shutdown grace once ctx is cancelled
+    workCtx, cancelWork := c.graceContext(ctx)
+    defer cancelWork()
+
+    for _, session := range candidates {
+        select {
+        case sem <- struct{}{}:
+        case <-ctx.Done():
+        }
+        if err := ctx.Err(); err != nil {
+            // Shutting down, only the cleanups already running are finished
+            result.addFailed(session, err)
+            c.stats.addFailure()
+            c.emit(PhaseFailed, session, err)
+            continue
+        }
+
+        wg.Add(1)
         go func(session SessionInfo) {
             defer wg.Done()
             defer func() { <-sem }()
 
-            if err := c.cleanupSession(ctx, session); err != nil {
-                log.Printf("Failed to cleanup session %s: %v", session.SessionID, err)
-                c.addError(fmt.Errorf("failed to cleanup session %s: %w", session.SessionID, err))
+            deleted, err := c.cleanupSessionTimed(workCtx, &session)
+            switch {
+            case err != nil:
+                c.logger.Error("Failed to cleanup session", "session_id", session.SessionID, "error", err)
+                result.addFailed(session, err)
+                c.stats.addFailure()
+                c.emit(PhaseFailed, session, err)
+            case deleted:
+                result.addDeleted(session)
+                c.stats.addDeleted()
+                c.emit(PhaseDeleted, session, nil)
+            default:
+                result.addSkipped(session)
+                c.emit(PhaseSkipped, session, nil)
             }
         }(session)
     }
 
     wg.Wait()
 
-    if len(c.errors) > 0 {
-        return fmt.Errorf("encountered %d errors during cleanup: %v", len(c.errors), c.errors)
+    if c.dryRun {
+        c.logger.Info("Dry run: no pods were deleted")
     }
 
-    log.Println("Pod cleanup completed successfully")
-    return nil
+    if c.deletions != nil && !c.dryRun {
+        if err := c.deletions.save(c.now()); err != nil {
+            c.logger.Warn("Failed to save deletion log", "error", err)
+        }
+    }
+
+    if len(result.Failed) > 0 {
+        return result, fmt.Errorf("encountered %d errors during cleanup: %v", len(result.Failed), result.Failed)
+    }
+
+    c.logger.Info("Pod cleanup completed successfully")
+    return result, nil
 }
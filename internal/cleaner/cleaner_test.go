@@ -0,0 +1,648 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader/downloadertest"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// testNow is the fixed time the cleaners under test measure ages against
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fakePodManager is an in-memory PodManager. Deleted pods disappear at once, so watching
+// them reports them deleted.
+type fakePodManager struct {
+	mutex     sync.Mutex
+	pods      []corev1.Pod
+	deleteErr map[string]error // errors returned when deleting the pods of the given names
+	deleted   []string
+	cordoned  map[string]bool // nodes currently cordoned
+	calls     []string        // deletions, cordons and uncordons in order, e.g. "cordon worker-1"
+	events    []string        // messages of the recorded pod events
+
+	before func(method, podName string) // called at the start of GetPodNodeName and DeletePod when set
+}
+
+func newFakePodManager(pods ...corev1.Pod) *fakePodManager {
+	return &fakePodManager{pods: pods, deleteErr: make(map[string]error), cordoned: make(map[string]bool)}
+}
+
+func (f *fakePodManager) find(namespace, podName string) (*corev1.Pod, error) {
+	for i := range f.pods {
+		if f.pods[i].Namespace == namespace && f.pods[i].Name == podName {
+			return &f.pods[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("pods"), podName)
+}
+
+func (f *fakePodManager) GetPodsByIP(_ context.Context, podIP string) ([]kubernetes.PodRef, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var refs []kubernetes.PodRef
+	for _, pod := range f.pods {
+		if kubernetes.CanonicalIP(pod.Status.PodIP) == kubernetes.CanonicalIP(podIP) {
+			refs = append(refs, kubernetes.PodRef{Namespace: pod.Namespace, Name: pod.Name})
+		}
+	}
+	return refs, nil
+}
+
+func (f *fakePodManager) GetPodNameBySessionID(_ context.Context, sessionID string) (kubernetes.PodRef, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, pod := range f.pods {
+		for _, container := range pod.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == kubernetes.SessionIDEnv && env.Value == sessionID {
+					return kubernetes.PodRef{Namespace: pod.Namespace, Name: pod.Name}, nil
+				}
+			}
+		}
+	}
+	return kubernetes.PodRef{}, kubernetes.ErrPodNotFound
+}
+
+func (f *fakePodManager) GetPodNameBySessionIDLabeled(_ context.Context, namespace, labelKey, sessionID string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, pod := range f.pods {
+		if pod.Namespace == namespace && pod.Labels[labelKey] == sessionID {
+			return pod.Name, nil
+		}
+	}
+	return "", kubernetes.ErrPodNotFound
+}
+
+func (f *fakePodManager) GetPod(_ context.Context, namespace, podName string) (*corev1.Pod, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	pod, err := f.find(namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	return pod.DeepCopy(), nil
+}
+
+func (f *fakePodManager) GetPodAnnotations(ctx context.Context, namespace, podName string) (map[string]string, error) {
+	pod, err := f.GetPod(ctx, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	return pod.Annotations, nil
+}
+
+func (f *fakePodManager) GetPodNodeName(ctx context.Context, namespace, podName string) (string, error) {
+	if f.before != nil {
+		f.before("GetPodNodeName", podName)
+	}
+	pod, err := f.GetPod(ctx, namespace, podName)
+	if err != nil {
+		return "", err
+	}
+	return pod.Spec.NodeName, nil
+}
+
+func (f *fakePodManager) ListPods(_ context.Context) ([]corev1.Pod, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return slices.Clone(f.pods), nil
+}
+
+func (f *fakePodManager) ListNodePods(_ context.Context, labelSelector string) ([]corev1.Pod, error) {
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var pods []corev1.Pod
+	for _, pod := range f.pods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+func (f *fakePodManager) DeletePod(_ context.Context, namespace, podName string, _ *int64) error {
+	if f.before != nil {
+		f.before("DeletePod", podName)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.deleteErr[podName]; err != nil {
+		return err
+	}
+	if _, err := f.find(namespace, podName); err != nil {
+		return err
+	}
+	f.pods = slices.DeleteFunc(f.pods, func(pod corev1.Pod) bool {
+		return pod.Namespace == namespace && pod.Name == podName
+	})
+	f.deleted = append(f.deleted, podName)
+	f.calls = append(f.calls, "delete "+podName)
+	return nil
+}
+
+func (f *fakePodManager) WatchPod(_ context.Context, namespace, podName string) (watch.Interface, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, err := f.find(namespace, podName); err != nil {
+		return nil, kubernetes.ErrPodDeleted
+	}
+	return watch.NewFake(), nil
+}
+
+func (f *fakePodManager) RemovePodFinalizers(context.Context, string, string) error {
+	return nil
+}
+
+func (f *fakePodManager) CordonNode(_ context.Context, nodeName string) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.cordoned[nodeName] {
+		return false, nil
+	}
+	f.cordoned[nodeName] = true
+	f.calls = append(f.calls, "cordon "+nodeName)
+	return true, nil
+}
+
+func (f *fakePodManager) isCordoned(nodeName string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.cordoned[nodeName]
+}
+
+func (f *fakePodManager) UncordonNode(_ context.Context, nodeName string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.cordoned, nodeName)
+	f.calls = append(f.calls, "uncordon "+nodeName)
+	return nil
+}
+
+func (f *fakePodManager) RecordPodEvent(_ context.Context, _, _, _, message string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.events = append(f.events, message)
+	return nil
+}
+
+func (f *fakePodManager) deletedPods() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return slices.Sorted(slices.Values(f.deleted))
+}
+
+// nodePod returns a pod of a grid node with the given IP
+func nodePod(name, podIP string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "selenium",
+			Name:              name,
+			Labels:            map[string]string{"app": "selenium-node"},
+			CreationTimestamp: metav1.NewTime(testNow.Add(-24 * time.Hour)),
+		},
+		Status: corev1.PodStatus{PodIP: podIP},
+	}
+}
+
+// testNode is a grid node of a test status, with one slot per session ID. An empty session
+// ID is an idle slot.
+type testNode struct {
+	uri          string
+	availability string
+	started      time.Duration // how long before testNow the sessions started
+	sessions     []string
+}
+
+// testStatus builds a grid status out of nodes
+func testStatus(nodes ...testNode) *downloader.Status {
+	status := &downloader.Status{}
+	for _, n := range nodes {
+		node := downloader.Node{URI: n.uri, Availability: n.availability}
+		for _, sessionID := range n.sessions {
+			var slot downloader.Slot
+			slot.Stereotype.BrowserName = "chrome"
+			if sessionID != "" {
+				slot.Session.SessionID = sessionID
+				slot.Session.Start = testNow.Add(-n.started).Format(time.RFC3339)
+			}
+			node.Slots = append(node.Slots, slot)
+		}
+		status.Value.Nodes = append(status.Value.Nodes, node)
+	}
+	return status
+}
+
+// newTestCleaner returns a cleaner on client with a fixed clock and silenced logs
+func newTestCleaner(client PodManager) *Cleaner {
+	c := NewCleaner(client, 2)
+	c.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.SetClock(func() time.Time { return testNow })
+	return c
+}
+
+func sessionIDs(sessions []SessionInfo) []string {
+	var ids []string
+	for _, session := range sessions {
+		ids = append(ids, session.SessionID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestCleanPods(t *testing.T) {
+	tests := []struct {
+		name      string
+		pods      []corev1.Pod
+		nodes     []testNode
+		configure func(*Cleaner, *fakePodManager)
+
+		wantDeleted []string // pod names
+		wantSkipped []string // session IDs
+		wantFailed  []string // session IDs
+		wantErr     string
+	}{
+		{
+			name:        "expired session is deleted",
+			pods:        []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes:       []testNode{{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"s1"}}},
+			wantDeleted: []string{"node-a"},
+		},
+		{
+			name:        "session within its max age is skipped",
+			pods:        []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes:       []testNode{{uri: "http://10.0.0.1:5555", started: 30 * time.Minute, sessions: []string{"s1"}}},
+			wantSkipped: []string{"s1"},
+		},
+		{
+			name: "only sessions over their max age are deleted",
+			pods: []corev1.Pod{nodePod("node-a", "10.0.0.1"), nodePod("node-b", "10.0.0.2")},
+			nodes: []testNode{
+				{uri: "http://10.0.0.1:5555", started: 3 * time.Hour, sessions: []string{"old"}},
+				{uri: "http://10.0.0.2:5555", started: 10 * time.Minute, sessions: []string{"young"}},
+			},
+			wantDeleted: []string{"node-a"},
+			wantSkipped: []string{"young"},
+		},
+		{
+			name:  "clock skew allowance keeps a session just over its max age",
+			pods:  []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes: []testNode{{uri: "http://10.0.0.1:5555", started: time.Hour + time.Minute, sessions: []string{"s1"}}},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				c.SetClockSkew(5*time.Minute, false)
+			},
+			wantSkipped: []string{"s1"},
+		},
+		{
+			name:  "browser max age overrides the default",
+			pods:  []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes: []testNode{{uri: "http://10.0.0.1:5555", started: 20 * time.Minute, sessions: []string{"s1"}}},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				c.SetBrowserMaxAges(map[string]time.Duration{"Chrome": 15 * time.Minute})
+			},
+			wantDeleted: []string{"node-a"},
+		},
+		{
+			name:  "min protected age wins over the max age",
+			pods:  []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes: []testNode{{uri: "http://10.0.0.1:5555", started: 20 * time.Minute, sessions: []string{"s1"}}},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				c.SetBrowserMaxAges(map[string]time.Duration{"chrome": 15 * time.Minute})
+				c.SetMinProtectedAge(30 * time.Minute)
+			},
+			wantSkipped: []string{"s1"},
+		},
+		{
+			name: "sessions on excluded IPs are left out",
+			pods: []corev1.Pod{nodePod("node-a", "10.0.0.1"), nodePod("node-b", "10.1.0.1")},
+			nodes: []testNode{
+				{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"s1"}},
+				{uri: "http://10.1.0.1:5555", started: 2 * time.Hour, sessions: []string{"s2"}},
+			},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				if err := c.SetExcludeIPs([]string{"10.1.0.0/16"}); err != nil {
+					panic(err)
+				}
+			},
+			wantDeleted: []string{"node-a"},
+		},
+		{
+			name:  "sessions on draining nodes are skipped",
+			pods:  []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes: []testNode{{uri: "http://10.0.0.1:5555", availability: "DRAINING", started: 2 * time.Hour, sessions: []string{"s1"}}},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				c.SetSkipDraining(true)
+			},
+			wantSkipped: []string{"s1"},
+		},
+		{
+			name: "protected pods are skipped",
+			pods: func() []corev1.Pod {
+				pod := nodePod("node-a", "10.0.0.1")
+				pod.Annotations = map[string]string{"selenium-cleaner/protect": "true"}
+				return []corev1.Pod{pod}
+			}(),
+			nodes: []testNode{{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"s1"}}},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				c.SetProtectAnnotation("selenium-cleaner/protect")
+			},
+			wantSkipped: []string{"s1"},
+		},
+		{
+			name:  "dry run deletes nothing",
+			pods:  []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes: []testNode{{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"s1"}}},
+			configure: func(c *Cleaner, _ *fakePodManager) {
+				c.SetDryRun(true)
+			},
+			wantSkipped: []string{"s1"},
+		},
+		{
+			name:        "idle slots and localhost nodes are ignored",
+			pods:        []corev1.Pod{nodePod("node-a", "10.0.0.1")},
+			nodes:       []testNode{{uri: "http://10.0.0.1:5555", sessions: []string{""}}, {uri: "http://localhost:5555", started: 2 * time.Hour, sessions: []string{"s1"}}},
+			wantDeleted: nil,
+		},
+		{
+			name: "failures are aggregated while other sessions are still cleaned",
+			pods: []corev1.Pod{nodePod("node-a", "10.0.0.1"), nodePod("node-b", "10.0.0.2")},
+			nodes: []testNode{
+				{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"s1"}},
+				{uri: "http://10.0.0.2:5555", started: 2 * time.Hour, sessions: []string{"s2"}},
+				{uri: "http://10.0.0.3:5555", started: 2 * time.Hour, sessions: []string{"s3"}},
+			},
+			configure: func(_ *Cleaner, f *fakePodManager) {
+				f.deleteErr["node-b"] = apierrors.NewForbidden(corev1.Resource("pods"), "node-b", errors.New("denied"))
+			},
+			wantDeleted: []string{"node-a"},
+			wantFailed:  []string{"s2", "s3"},
+			wantErr:     "encountered 2 errors during cleanup",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakePodManager(tt.pods...)
+			c := newTestCleaner(client)
+			if tt.configure != nil {
+				tt.configure(c, client)
+			}
+
+			result, err := c.CleanPods(context.Background(), testStatus(tt.nodes...), time.Hour)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("CleanPods() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("CleanPods() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+
+			if got := client.deletedPods(); !slices.Equal(got, tt.wantDeleted) {
+				t.Errorf("deleted pods = %v, want %v", got, tt.wantDeleted)
+			}
+			if got := slices.Sorted(slices.Values(result.Deleted)); !slices.Equal(got, tt.wantDeleted) {
+				t.Errorf("result.Deleted = %v, want %v", got, tt.wantDeleted)
+			}
+			if got := sessionIDs(result.Skipped); !slices.Equal(got, tt.wantSkipped) {
+				t.Errorf("result.Skipped = %v, want %v", got, tt.wantSkipped)
+			}
+			if got := sessionIDs(result.FailedSessions); !slices.Equal(got, tt.wantFailed) {
+				t.Errorf("result.FailedSessions = %v, want %v", got, tt.wantFailed)
+			}
+			for _, id := range tt.wantFailed {
+				if result.Failed[id] == nil {
+					t.Errorf("result.Failed[%s] is nil", id)
+				}
+			}
+		})
+	}
+}
+
+func TestCleanPodsMissingPodWrapsErrPodNotFound(t *testing.T) {
+	c := newTestCleaner(newFakePodManager())
+	status := testStatus(testNode{uri: "http://10.0.0.9:5555", started: 2 * time.Hour, sessions: []string{"s1"}})
+
+	result, err := c.CleanPods(context.Background(), status, time.Hour)
+	if err == nil {
+		t.Fatal("CleanPods() error = nil, want an error for the session without a pod")
+	}
+	if !errors.Is(result.Failed["s1"], kubernetes.ErrPodNotFound) {
+		t.Errorf("result.Failed[s1] = %v, want it to wrap ErrPodNotFound", result.Failed["s1"])
+	}
+}
+
+func TestCleanPodsSafetyValve(t *testing.T) {
+	var pods []corev1.Pod
+	var nodes []testNode
+	for i := range 6 {
+		ip := fmt.Sprintf("10.0.0.%d", i+1)
+		pods = append(pods, nodePod(fmt.Sprintf("node-%d", i), ip))
+		nodes = append(nodes, testNode{uri: "http://" + ip + ":5555", started: 2 * time.Hour, sessions: []string{fmt.Sprintf("s%d", i)}})
+	}
+	client := newFakePodManager(pods...)
+	c := newTestCleaner(client)
+	c.SetSafetyValve(0.5, 0)
+
+	result, err := c.CleanPods(context.Background(), testStatus(nodes...), time.Hour)
+	if err != nil {
+		t.Fatalf("CleanPods() error = %v, want nil as no deletion failed", err)
+	}
+	if !errors.Is(result.Refused, ErrTooManyDeletions) {
+		t.Errorf("result.Refused = %v, want ErrTooManyDeletions", result.Refused)
+	}
+	if deleted := client.deletedPods(); len(deleted) != 0 {
+		t.Errorf("deleted pods = %v, want none", deleted)
+	}
+	if len(result.Skipped) != len(nodes) {
+		t.Errorf("len(result.Skipped) = %d, want %d", len(result.Skipped), len(nodes))
+	}
+}
+
+func TestNodeIPFromURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{uri: "http://10.0.0.1", want: "10.0.0.1"},
+		{uri: "http://10.0.0.1:5555", want: "10.0.0.1"},
+		{uri: "http://[fd00::1]:5555", want: "fd00::1"},
+		{uri: "http://[fd00:0:0::0001]:5555", want: "fd00::1"},
+		{uri: "http://[fd00::1]", want: "fd00::1"},
+		{uri: "http://selenium-node-chrome.selenium.svc:5555", want: "selenium-node-chrome.selenium.svc"},
+		{uri: "http://localhost:5555", want: ""},
+		{uri: "http://%zz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := nodeIPFromURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nodeIPFromURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("nodeIPFromURI() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanPodsFromFakeGrid(t *testing.T) {
+	grid := downloadertest.NewFakeGrid(testStatus(
+		testNode{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"old"}},
+		testNode{uri: "http://10.0.0.2:5555", started: 10 * time.Minute, sessions: []string{"young"}},
+	))
+	defer grid.Close()
+
+	opts := downloader.DefaultOptions()
+	opts.InMemory = true
+	status, err := downloader.DownloadStatus(context.Background(), grid.StatusURL(), opts)
+	if err != nil {
+		t.Fatalf("DownloadStatus() error = %v", err)
+	}
+
+	client := newFakePodManager(nodePod("node-a", "10.0.0.1"), nodePod("node-b", "10.0.0.2"))
+	if _, err := newTestCleaner(client).CleanPods(context.Background(), status, time.Hour); err != nil {
+		t.Fatalf("CleanPods() error = %v", err)
+	}
+	if got := client.deletedPods(); !slices.Equal(got, []string{"node-a"}) {
+		t.Errorf("deleted pods = %v, want [node-a]", got)
+	}
+}
+
+func TestCleanPodsRecordsDecisionAge(t *testing.T) {
+	client := newFakePodManager(nodePod("node-a", "10.0.0.1"), nodePod("node-b", "10.0.0.2"))
+	c := newTestCleaner(client)
+	c.SetClockSkew(5*time.Minute, false)
+	c.SetDryRun(true)
+	status := testStatus(
+		testNode{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"old"}},
+		testNode{uri: "http://10.0.0.2:5555", started: 30 * time.Minute, sessions: []string{"young"}},
+	)
+
+	result, err := c.CleanPods(context.Background(), status, time.Hour)
+	if err != nil {
+		t.Fatalf("CleanPods() error = %v", err)
+	}
+	want := map[string]time.Duration{"old": 115 * time.Minute, "young": 25 * time.Minute}
+	for _, session := range result.Skipped {
+		if session.Age != want[session.SessionID] {
+			t.Errorf("Age of %s = %v, want %v", session.SessionID, session.Age, want[session.SessionID])
+		}
+	}
+	if len(result.Skipped) != len(want) {
+		t.Errorf("len(result.Skipped) = %d, want %d", len(result.Skipped), len(want))
+	}
+}
+
+func TestRecordCleanedEventAge(t *testing.T) {
+	tests := []struct {
+		name    string
+		session SessionInfo
+		want    string
+	}{
+		{
+			name:    "expired session",
+			session: SessionInfo{SessionID: "s1", Age: 95 * time.Minute, MaxAge: time.Hour},
+			want:    "Deleted pod of Selenium session s1, age 1h35m0s, exceeded max age 1h0m0s",
+		},
+		{
+			name:    "unknown start time",
+			session: SessionInfo{SessionID: "s2"},
+			want:    "Deleted pod of Selenium session s2, cleaned on request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakePodManager()
+			c := newTestCleaner(client)
+			c.recordCleanedEvent(context.Background(), c.logger, tt.session)
+			if len(client.events) != 1 || client.events[0] != tt.want {
+				t.Errorf("events = %q, want [%q]", client.events, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanPodsUncordonsAfterLastSessionOnNode(t *testing.T) {
+	onNode := func(pod corev1.Pod, nodeName string) corev1.Pod {
+		pod.Spec.NodeName = nodeName
+		return pod
+	}
+	client := newFakePodManager(
+		onNode(nodePod("node-a", "10.0.0.1"), "worker-1"),
+		onNode(nodePod("node-b", "10.0.0.2"), "worker-1"),
+		onNode(nodePod("node-c", "10.0.0.3"), "worker-2"),
+	)
+	client.cordoned["worker-2"] = true // cordoned by someone else
+	// node-a's cleanup cordons worker-1 first. Both deletions on it are then in flight
+	// together, and node-b's waits a while for node-a's cleanup to uncordon the node early.
+	waitFor := func(cordoned bool) {
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for time.Now().Before(deadline) && client.isCordoned("worker-1") != cordoned {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	var inFlight sync.WaitGroup
+	inFlight.Add(2)
+	client.before = func(method, podName string) {
+		switch {
+		case podName == "node-c":
+		case method == "GetPodNodeName" && podName == "node-b":
+			waitFor(true)
+		case method == "DeletePod":
+			inFlight.Done()
+			inFlight.Wait()
+			if podName == "node-b" {
+				waitFor(false)
+			}
+		}
+	}
+	c := newTestCleaner(client)
+	c.SetCordon(true, true)
+	status := testStatus(
+		testNode{uri: "http://10.0.0.1:5555", started: 2 * time.Hour, sessions: []string{"s1"}},
+		testNode{uri: "http://10.0.0.2:5555", started: 2 * time.Hour, sessions: []string{"s2"}},
+		testNode{uri: "http://10.0.0.3:5555", started: 2 * time.Hour, sessions: []string{"s3"}},
+	)
+
+	if _, err := c.CleanPods(context.Background(), status, time.Hour); err != nil {
+		t.Fatalf("CleanPods() error = %v", err)
+	}
+
+	var worker1 []string
+	for _, call := range client.calls {
+		if strings.HasSuffix(call, "worker-1") || call == "delete node-a" || call == "delete node-b" {
+			worker1 = append(worker1, call)
+		}
+	}
+	if len(worker1) != 4 || worker1[0] != "cordon worker-1" || worker1[3] != "uncordon worker-1" {
+		t.Errorf("calls on worker-1 = %v, want one cordon, both deletions, then one uncordon", worker1)
+	}
+	if slices.Contains(client.calls, "uncordon worker-2") {
+		t.Errorf("calls = %v, want worker-2 left cordoned", client.calls)
+	}
+	if len(c.cordons.nodes) != 0 {
+		t.Errorf("cordons.nodes = %v, want none held after the run", c.cordons.nodes)
+	}
+}
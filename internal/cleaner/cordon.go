@@ -0,0 +1,95 @@
+package cleaner
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// SetCordon makes the cleaner cordon the Kubernetes node hosting a pod before deleting it,
+// so no fresh session is scheduled onto it in the meantime. With uncordon the node is made
+// schedulable again once the pods of all sessions on it are handled; nodes that were already
+// cordoned are left alone.
+func (c *Cleaner) SetCordon(cordon, uncordon bool) {
+	c.cordon = cordon
+	c.uncordon = uncordon
+}
+
+// cordonedNodes counts the in-flight cleanups per Kubernetes node, so that sessions cleaned
+// up in parallel on one node cordon it once and uncordon it after the last of them
+type cordonedNodes struct {
+	mutex sync.Mutex
+	nodes map[string]*cordonedNode
+}
+
+type cordonedNode struct {
+	sessions int  // cleanups on the node still in flight
+	owned    bool // cordoned by the cleaner rather than before it
+}
+
+// cordonPodNode cordons the node of the pod unless another cleanup already holds it, and
+// returns the node name for releasePodNode. Cordoning is best effort: failures, including
+// missing RBAC permissions, are logged and the deletion goes ahead.
+func (c *Cleaner) cordonPodNode(ctx context.Context, logger *slog.Logger, namespace, podName string) string {
+	nodeName, err := c.k8sClient.GetPodNodeName(ctx, namespace, podName)
+	if err != nil {
+		logger.Warn("Failed to resolve the node of the pod, not cordoning", "error", err)
+		return ""
+	}
+	if nodeName == "" {
+		return ""
+	}
+
+	// The lock is held across the API calls, so a second cleanup on the node waits for the
+	// outcome of the first one's cordon instead of racing it
+	c.cordons.mutex.Lock()
+	defer c.cordons.mutex.Unlock()
+	if node, ok := c.cordons.nodes[nodeName]; ok {
+		node.sessions++
+		return nodeName
+	}
+	node := &cordonedNode{sessions: 1}
+	if c.cordons.nodes == nil {
+		c.cordons.nodes = make(map[string]*cordonedNode)
+	}
+	c.cordons.nodes[nodeName] = node
+
+	logger = logger.With("node", nodeName)
+	cordoned, err := c.k8sClient.CordonNode(ctx, nodeName)
+	switch {
+	case apierrors.IsForbidden(err):
+		logger.Warn("Not permitted to cordon nodes, skipping", "error", err)
+	case err != nil:
+		logger.Warn("Failed to cordon node, skipping", "error", err)
+	case !cordoned:
+		logger.Info("Node is already cordoned")
+	default:
+		logger.Info("Cordoned node")
+		node.owned = true
+	}
+	return nodeName
+}
+
+// releasePodNode ends a cleanup's hold on a node returned by cordonPodNode. The last cleanup
+// on a node cordoned by the cleaner makes it schedulable again when uncordoning is enabled.
+func (c *Cleaner) releasePodNode(ctx context.Context, logger *slog.Logger, nodeName string) {
+	c.cordons.mutex.Lock()
+	defer c.cordons.mutex.Unlock()
+	node := c.cordons.nodes[nodeName]
+	if node.sessions--; node.sessions > 0 {
+		return
+	}
+	delete(c.cordons.nodes, nodeName)
+	if !node.owned || !c.uncordon {
+		return
+	}
+
+	logger = logger.With("node", nodeName)
+	if err := c.k8sClient.UncordonNode(context.WithoutCancel(ctx), nodeName); err != nil {
+		logger.Warn("Failed to uncordon node", "error", err)
+		return
+	}
+	logger.Info("Uncordoned node")
+}
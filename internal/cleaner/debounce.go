@@ -0,0 +1,110 @@
+package cleaner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+// deletionLog remembers recently deleted pods and sessions so that a stale grid status
+// cannot make the cleaner delete a freshly recreated pod again within the cooldown window.
+// Entries are persisted to a file so the log survives between runs.
+type deletionLog struct {
+	path    string
+	window  time.Duration
+	mutex   sync.Mutex
+	entries map[string]time.Time
+}
+
+// loadDeletionLog reads the deletion log from path, dropping entries older than window at
+// now. A missing file yields an empty log.
+func loadDeletionLog(path string, window time.Duration, now time.Time) (*deletionLog, error) {
+	dl := &deletionLog{
+		path:    path,
+		window:  window,
+		entries: make(map[string]time.Time),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return dl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deletion log: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &dl.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse deletion log %s: %w", path, err)
+	}
+	dl.dropLegacyPodKeys()
+	dl.prune(now)
+
+	return dl, nil
+}
+
+func podKey(pod kubernetes.PodRef) string { return "pod/" + pod.String() }
+func sessionKey(sessionID string) string  { return "session/" + sessionID }
+
+// dropLegacyPodKeys drops pod entries written before pods were keyed by namespace. A pod
+// name alone could match a pod of the same name in another namespace.
+func (dl *deletionLog) dropLegacyPodKeys() {
+	for key := range dl.entries {
+		if name, ok := strings.CutPrefix(key, "pod/"); ok && !strings.Contains(name, "/") {
+			delete(dl.entries, key)
+		}
+	}
+}
+
+// prune drops entries that fell out of the cooldown window
+func (dl *deletionLog) prune(now time.Time) {
+	for key, deletedAt := range dl.entries {
+		if now.Sub(deletedAt) > dl.window {
+			delete(dl.entries, key)
+		}
+	}
+}
+
+// recent reports whether key was deleted within the cooldown window before now and when
+func (dl *deletionLog) recent(key string, now time.Time) (time.Time, bool) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	deletedAt, ok := dl.entries[key]
+	if !ok || now.Sub(deletedAt) > dl.window {
+		return time.Time{}, false
+	}
+	return deletedAt, true
+}
+
+// record marks the given keys as deleted at now
+func (dl *deletionLog) record(now time.Time, keys ...string) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	for _, key := range keys {
+		dl.entries[key] = now
+	}
+}
+
+// save writes the log, pruned at now, back to its file
+func (dl *deletionLog) save(now time.Time) error {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	dl.prune(now)
+	data, err := json.MarshalIndent(dl.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode deletion log: %w", err)
+	}
+
+	if err := os.WriteFile(dl.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write deletion log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package cleaner
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+func TestDeletionLogKeysPodsByNamespace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deletions.json")
+	now := time.Now()
+	data, err := json.Marshal(map[string]time.Time{
+		"pod/chrome-0":      now, // written before pods were keyed by namespace
+		"pod/ns-a/chrome-0": now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dl, err := loadDeletionLog(path, time.Hour, now)
+	if err != nil {
+		t.Fatalf("loadDeletionLog() error = %v", err)
+	}
+	if _, ok := dl.entries["pod/chrome-0"]; ok {
+		t.Error("legacy pod entry was kept, want it dropped on load")
+	}
+	if _, ok := dl.recent(podKey(kubernetes.PodRef{Namespace: "ns-a", Name: "chrome-0"}), now); !ok {
+		t.Error("recent(ns-a/chrome-0) = false, want true")
+	}
+	if _, ok := dl.recent(podKey(kubernetes.PodRef{Namespace: "ns-b", Name: "chrome-0"}), now); ok {
+		t.Error("recent(ns-b/chrome-0) = true, want false for the pod of the same name in another namespace")
+	}
+}
+
+func TestDeletionLogUsesGivenClock(t *testing.T) {
+	dl, err := loadDeletionLog(filepath.Join(t.TempDir(), "deletions.json"), time.Hour, testNow)
+	if err != nil {
+		t.Fatalf("loadDeletionLog() error = %v", err)
+	}
+	key := sessionKey("s1")
+	dl.record(testNow, key)
+
+	if _, ok := dl.recent(key, testNow.Add(30*time.Minute)); !ok {
+		t.Error("recent() = false within the window, want true")
+	}
+	if _, ok := dl.recent(key, testNow.Add(2*time.Hour)); ok {
+		t.Error("recent() = true after the window, want false")
+	}
+}
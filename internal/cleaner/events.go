@@ -0,0 +1,32 @@
+package cleaner
+
+// Phase identifies a step of a session's cleanup
+type Phase string
+
+const (
+	PhaseParsed   Phase = "parsed"   // Session was found in the grid status
+	PhaseSkipped  Phase = "skipped"  // Session was left alone
+	PhaseDeleting Phase = "deleting" // Pod deletion is about to be issued
+	PhaseDeleted  Phase = "deleted"  // Pod deletion was confirmed
+	PhaseFailed   Phase = "failed"   // Cleanup failed, see Err
+)
+
+// CleanupEvent reports the progress of a session's cleanup
+type CleanupEvent struct {
+	Phase   Phase
+	Session SessionInfo
+	Err     error // Set for PhaseFailed
+}
+
+// OnEvent registers a callback receiving progress events during CleanPods.
+// The callback is invoked from the cleanup workers, so it must be safe for concurrent use.
+func (c *Cleaner) OnEvent(fn func(CleanupEvent)) {
+	c.onEvent = fn
+}
+
+// emit sends an event to the registered callback, if any
+func (c *Cleaner) emit(phase Phase, session SessionInfo, err error) {
+	if c.onEvent != nil {
+		c.onEvent(CleanupEvent{Phase: phase, Session: session, Err: err})
+	}
+}
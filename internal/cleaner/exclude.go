@@ -0,0 +1,85 @@
+package cleaner
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ipRule matches node IPs either exactly or by CIDR range
+type ipRule struct {
+	raw     string
+	network *net.IPNet
+}
+
+func (r ipRule) matches(ip net.IP) bool {
+	return r.network.Contains(ip)
+}
+
+// parseIPRules parses exact IPs and CIDR ranges
+func parseIPRules(values []string) ([]ipRule, error) {
+	rules := make([]ipRule, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if strings.Contains(value, "/") {
+			_, network, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", value, err)
+			}
+			rules = append(rules, ipRule{raw: value, network: network})
+			continue
+		}
+
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		rules = append(rules, ipRule{raw: value, network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}})
+	}
+	return rules, nil
+}
+
+// SetExcludeIPs drops sessions on nodes matching any of the given IPs or CIDR ranges
+// before they are considered for cleanup
+func (c *Cleaner) SetExcludeIPs(values []string) error {
+	rules, err := parseIPRules(values)
+	if err != nil {
+		return err
+	}
+	c.excludeIPs = rules
+	return nil
+}
+
+// SetExcludeBrowsers drops sessions of the given browsers, matched case-insensitively
+// against the browser of the slot stereotype or session capabilities, before their age is
+// checked
+func (c *Cleaner) SetExcludeBrowsers(browsers []string) {
+	c.excludeBrowsers = make(map[string]bool, len(browsers))
+	for _, browser := range browsers {
+		if browser = strings.TrimSpace(browser); browser != "" {
+			c.excludeBrowsers[strings.ToLower(browser)] = true
+		}
+	}
+}
+
+// excludedBy returns the exclusion rule matching the node IP, if any
+func (c *Cleaner) excludedBy(nodeIP string) (string, bool) {
+	ip := net.ParseIP(nodeIP)
+	if ip == nil {
+		return "", false
+	}
+	for _, rule := range c.excludeIPs {
+		if rule.matches(ip) {
+			return rule.raw, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,72 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrDeletionTimeout is wrapped in the cleanup error of a session whose pod was deleted but
+// did not disappear within the deletion timeout
+var ErrDeletionTimeout = errors.New("pod deletion timed out")
+
+// SetForceDelete enables force deletion as a last resort for pods stuck in Terminating.
+// A pod still present after is deleted again with a zero grace period, and its
+// finalizers are removed as well when removeFinalizers is set. Zero disables it.
+func (c *Cleaner) SetForceDelete(after time.Duration, removeFinalizers bool) {
+	c.forceAfter = after
+	c.forceFinalizers = removeFinalizers
+}
+
+// confirmPodDeletion waits for a deleted pod to disappear, force deleting it once the
+// force window has passed when force deletion is enabled
+func (c *Cleaner) confirmPodDeletion(ctx context.Context, namespace, podName string) error {
+	if c.forceAfter <= 0 {
+		return c.waitForPodDeletion(ctx, namespace, podName, c.deletionTimeout)
+	}
+
+	err := c.waitForPodDeletion(ctx, namespace, podName, c.forceAfter)
+	if !errors.Is(err, ErrDeletionTimeout) {
+		return err
+	}
+
+	if err := c.forceDeletePod(ctx, namespace, podName); err != nil {
+		return err
+	}
+	return c.waitForPodDeletion(ctx, namespace, podName, c.deletionTimeout)
+}
+
+// forceDeletePod re-issues the delete with a zero grace period and optionally strips the
+// pod's finalizers
+func (c *Cleaner) forceDeletePod(ctx context.Context, namespace, podName string) error {
+	logger := c.logger.With("namespace", namespace, "pod", podName)
+	logger.Error("FORCE DELETING pod still present after the force window",
+		"force_after", c.forceAfter.String(), "remove_finalizers", c.forceFinalizers)
+
+	if err := c.waitDeleteSlot(ctx); err != nil {
+		return err
+	}
+	var zero int64
+	err := c.k8sClient.DeletePod(ctx, namespace, podName, &zero)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to force delete pod %s: %w", podName, err)
+	}
+
+	if c.forceFinalizers {
+		logger.Error("FORCE REMOVING finalizers of pod")
+		err := c.k8sClient.RemovePodFinalizers(ctx, namespace, podName)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove finalizers of pod %s: %w", podName, err)
+		}
+	}
+	return nil
+}
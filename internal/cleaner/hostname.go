@@ -0,0 +1,88 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+// maxHostnameLength is the length pod hostnames are truncated to, a DNS label
+const maxHostnameLength = 63
+
+// SetResolveHostnames enables cleaning up sessions of nodes that registered with a DNS
+// name instead of an IP, e.g. http://selenium-node-chrome-xyz.selenium.svc:5555. Their pod
+// is the one whose name or hostname is the first label of the name, or else the one whose
+// IP the name resolves to. Disabled, such sessions are skipped.
+func (c *Cleaner) SetResolveHostnames(enabled bool) {
+	c.resolveHostnames = enabled
+}
+
+// isHostname reports whether the node host of a session is a DNS name rather than an IP
+func isHostname(host string) bool {
+	return net.ParseIP(host) == nil
+}
+
+// podsByHostname returns the pods behind a node hostname, by pod name first and by DNS
+// lookup second. Pods on excluded IPs are left out.
+func (c *Cleaner) podsByHostname(ctx context.Context, host string) ([]kubernetes.PodRef, error) {
+	index := c.pods
+	if index == nil {
+		index = newPodIndex(c.k8sClient, c.sessionLabel)
+	}
+
+	label, _, _ := strings.Cut(host, ".")
+	refs, err := index.podsByName(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(refs) == 0 {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve node host %s: %w", host, err)
+		}
+		for _, addr := range addrs {
+			matches, err := index.podsByIP(ctx, addr)
+			if err != nil {
+				return nil, err
+			}
+			refs = append(refs, matches...)
+		}
+	}
+
+	return c.allowedPods(index, refs, host), nil
+}
+
+// podsByHostID returns the pods whose name or hostname is the host ID of a slot, which is
+// the pod name in many deployments. Pods on excluded IPs are left out.
+func (c *Cleaner) podsByHostID(ctx context.Context, hostID string) ([]kubernetes.PodRef, error) {
+	index := c.pods
+	if index == nil {
+		index = newPodIndex(c.k8sClient, c.sessionLabel)
+	}
+
+	refs, err := index.podsByName(ctx, hostID)
+	if err != nil {
+		return nil, err
+	}
+	return c.allowedPods(index, refs, hostID), nil
+}
+
+// allowedPods leaves out the pods whose IP is excluded
+func (c *Cleaner) allowedPods(index *podIndex, refs []kubernetes.PodRef, host string) []kubernetes.PodRef {
+	var allowed []kubernetes.PodRef
+	for _, ref := range refs {
+		if pod, ok := index.pod(ref); ok {
+			if rule, excluded := c.excludedBy(pod.Status.PodIP); excluded {
+				c.logger.Info("Pod of node host is excluded, ignoring it",
+					"host", host, "pod", ref.String(), "pod_ip", pod.Status.PodIP, "rule", rule)
+				continue
+			}
+		}
+		allowed = append(allowed, ref)
+	}
+	return allowed
+}
@@ -0,0 +1,41 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// cleanedEventReason is the reason of the event recorded for a deleted pod
+const cleanedEventReason = "SeleniumSessionCleaned"
+
+// SetEmitEvents makes the cleaner record a Kubernetes event involving every pod it deletes,
+// with reason SeleniumSessionCleaned, so kubectl get events shows who removed the pod and why
+func (c *Cleaner) SetEmitEvents(enabled bool) {
+	c.emitEvents = enabled
+}
+
+// recordCleanedEvent records the deletion of the session's pod. It is best effort: failures,
+// including missing RBAC permissions, are logged and the cleanup goes on.
+func (c *Cleaner) recordCleanedEvent(ctx context.Context, logger *slog.Logger, session SessionInfo) {
+	message := fmt.Sprintf("Deleted pod of Selenium session %s", session.SessionID)
+	if session.Age > 0 {
+		message += fmt.Sprintf(", age %v", session.Age.Round(time.Second))
+	}
+	if session.MaxAge > 0 {
+		message += fmt.Sprintf(", exceeded max age %v", session.MaxAge)
+	} else {
+		message += ", cleaned on request"
+	}
+
+	err := c.k8sClient.RecordPodEvent(context.WithoutCancel(ctx), session.Namespace, session.PodName, cleanedEventReason, message)
+	switch {
+	case apierrors.IsForbidden(err):
+		logger.Warn("Not permitted to create events, skipping", "error", err)
+	case err != nil:
+		logger.Warn("Failed to record cleanup event", "error", err)
+	}
+}
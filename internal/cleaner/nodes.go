@@ -0,0 +1,47 @@
+package cleaner
+
+import (
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+)
+
+// NodeSummary counts the nodes of the grid status a run inspected. Nodes reported without
+// slots, e.g. while they register, run no session the cleaner can see, so a status made of
+// them looks like an empty grid although it is a degraded one.
+type NodeSummary struct {
+	Inspected    int      // Nodes in the status
+	WithSessions int      // Nodes running at least one session
+	WithoutSlots []string // URIs of the nodes reported with a missing or empty slot list
+}
+
+// Degraded reports whether some nodes came without slots
+func (s NodeSummary) Degraded() bool {
+	return len(s.WithoutSlots) > 0
+}
+
+// summarizeNodes counts the nodes of the status
+func summarizeNodes(status *downloader.Status) NodeSummary {
+	summary := NodeSummary{Inspected: len(status.Value.Nodes)}
+	for _, node := range status.Value.Nodes {
+		if len(node.Slots) == 0 {
+			summary.WithoutSlots = append(summary.WithoutSlots, node.URI)
+			continue
+		}
+		for _, slot := range node.Slots {
+			if slot.Session.SessionID != "" {
+				summary.WithSessions++
+				break
+			}
+		}
+	}
+	return summary
+}
+
+// logNodeSummary logs the node counts, warning about a degraded status
+func (c *Cleaner) logNodeSummary(summary NodeSummary) {
+	c.logger.Info("Inspected grid nodes", "nodes", summary.Inspected,
+		"with_sessions", summary.WithSessions, "without_slots", len(summary.WithoutSlots))
+	if summary.Degraded() {
+		c.logger.Warn("Grid status is degraded, some nodes were reported without slots and their sessions cannot be checked",
+			"count", len(summary.WithoutSlots), "uris", summary.WithoutSlots)
+	}
+}
@@ -0,0 +1,47 @@
+package cleaner
+
+import (
+	"net"
+	"strings"
+)
+
+// SetOnly restricts CleanPods to the sessions on nodes matching any of the given IPs or
+// CIDR ranges and the sessions with any of the given IDs. Selected sessions are cleaned up
+// whatever their max age and node availability; the min protected age, exclusions and pod
+// protection still apply. Empty lists lift the restriction.
+func (c *Cleaner) SetOnly(ips, sessionIDs []string) error {
+	rules, err := parseIPRules(ips)
+	if err != nil {
+		return err
+	}
+	c.onlyIPs = rules
+	c.onlySessions = make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			c.onlySessions[id] = true
+		}
+	}
+	return nil
+}
+
+// targeted reports whether SetOnly restricts the cleanup to selected sessions
+func (c *Cleaner) targeted() bool {
+	return len(c.onlyIPs) > 0 || len(c.onlySessions) > 0
+}
+
+// selected reports whether the session matches an IP or ID given to SetOnly
+func (c *Cleaner) selected(session SessionInfo) bool {
+	if c.onlySessions[session.SessionID] {
+		return true
+	}
+	ip := net.ParseIP(session.NodeIP)
+	if ip == nil {
+		return false
+	}
+	for _, rule := range c.onlyIPs {
+		if rule.matches(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+)
+
+// FindOrphans returns the node pods selected by labelSelector that are older than minAge
+// and belong to a grid node with slots but no running session. Nodes are tied to their
+// pods like sessions are: by IP, by hostname when hostname resolution is enabled, and by
+// the host ID of their slots. Pods that cannot be tied to a node of the status, pods of
+// nodes without slots and pods on excluded IPs are never reported, since nothing tells
+// whether they run a session.
+func (c *Cleaner) FindOrphans(ctx context.Context, status *downloader.Status, labelSelector string, minAge time.Duration) ([]NodePod, error) {
+	c.pods = newPodIndex(c.k8sClient, c.sessionLabel)
+	defer func() { c.pods = nil }()
+
+	idle := make(map[kubernetes.PodRef]bool)
+	busy := make(map[kubernetes.PodRef]bool)
+	for _, node := range status.Value.Nodes {
+		if len(node.Slots) == 0 {
+			c.logger.Debug("Node has no slots, leaving its pods out of the orphans", "uri", node.URI)
+			continue
+		}
+		refs, err := c.nodePods(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		running := slices.ContainsFunc(node.Slots, func(slot downloader.Slot) bool {
+			return slot.Session.SessionID != ""
+		})
+		for _, ref := range refs {
+			if running {
+				busy[ref] = true
+			} else {
+				idle[ref] = true
+			}
+		}
+	}
+
+	pods, err := c.k8sClient.ListNodePods(ctx, labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node pods: %w", err)
+	}
+
+	var orphans []NodePod
+	for _, pod := range pods {
+		ref := kubernetes.PodRef{Namespace: pod.Namespace, Name: pod.Name}
+		if !idle[ref] || busy[ref] || pod.DeletionTimestamp != nil {
+			continue
+		}
+		nodePod := newNodePod(pod)
+		if _, excluded := c.excludedBy(nodePod.IP); excluded {
+			continue
+		}
+		nodePod.Age = c.now().Sub(nodePod.Created)
+		if nodePod.Age <= minAge {
+			continue
+		}
+		orphans = append(orphans, nodePod)
+	}
+	return orphans, nil
+}
+
+// nodePods returns the pods behind a grid node. Nodes that cannot be resolved, such as
+// localhost nodes or hostnames that do not resolve, have none.
+func (c *Cleaner) nodePods(ctx context.Context, node downloader.Node) ([]kubernetes.PodRef, error) {
+	host, err := nodeIPFromURI(node.URI)
+	if err != nil || host == "" {
+		c.logger.Warn("Cannot resolve the pods of node, leaving them out of the orphans", "uri", node.URI)
+		return nil, nil
+	}
+
+	var refs []kubernetes.PodRef
+	switch {
+	case !isHostname(host):
+		refs, err = c.pods.podsByIP(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pods of node %s: %w", host, err)
+		}
+	case c.resolveHostnames:
+		refs, err = c.podsByHostname(ctx, host)
+		if err != nil {
+			c.logger.Warn("Cannot resolve the pods of node, leaving them out of the orphans", "uri", node.URI, "error", err)
+		}
+	}
+
+	for _, slot := range node.Slots {
+		if len(refs) > 0 {
+			break
+		}
+		if slot.ID.HostID == "" {
+			continue
+		}
+		refs, err = c.podsByHostID(ctx, slot.ID.HostID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pods of host ID %s: %w", slot.ID.HostID, err)
+		}
+	}
+	return refs, nil
+}
+
+// CleanOrphans deletes the node pods found by FindOrphans, honouring protection, dry run,
+// the delete rate limit and retries. It returns the orphans that were deleted.
+func (c *Cleaner) CleanOrphans(ctx context.Context, status *downloader.Status, labelSelector string, minAge time.Duration) ([]NodePod, error) {
+	orphans, err := c.FindOrphans(ctx, status, labelSelector, minAge)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []NodePod
+	var failed int
+	for _, orphan := range orphans {
+		logger := c.logger.With("namespace", orphan.Namespace, "pod", orphan.Name, "pod_ip", orphan.IP,
+			"age", orphan.Age.Round(time.Second).String())
+
+		protected, err := c.isProtected(ctx, orphan.Namespace, orphan.Name)
+		if err != nil {
+			logger.Error("Failed to check protection of orphaned pod", "error", err)
+			failed++
+			continue
+		}
+		if protected {
+			logger.Info("Orphaned pod is protected, skipping", "annotation", c.protectAnnotation)
+			continue
+		}
+
+		if c.dryRun {
+			logger.Info("Dry run: would delete orphaned pod")
+			continue
+		}
+
+		logger.Info("Deleting orphaned pod without a session")
+		gone, err := c.deletePodWithRetry(ctx, orphan.Namespace, orphan.Name)
+		if err == nil && !gone {
+			err = c.confirmPodDeletion(ctx, orphan.Namespace, orphan.Name)
+		}
+		if err != nil {
+			logger.Error("Failed to delete orphaned pod", "error", err)
+			c.stats.addFailure()
+			failed++
+			continue
+		}
+		c.stats.addDeleted()
+		deleted = append(deleted, orphan)
+	}
+
+	if failed > 0 {
+		return deleted, fmt.Errorf("failed to delete %d of %d orphaned pods", failed, len(orphans))
+	}
+	return deleted, nil
+}
@@ -0,0 +1,124 @@
+package cleaner
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestFindOrphans(t *testing.T) {
+	young := nodePod("node-young", "10.0.0.6")
+	young.CreationTimestamp.Time = testNow.Add(-5 * time.Minute)
+
+	tests := []struct {
+		name             string
+		pods             []corev1.Pod
+		status           *downloader.Status
+		resolveHostnames bool
+		want             []string
+	}{
+		{
+			name: "idle node pod is an orphan, busy one is not",
+			pods: []corev1.Pod{nodePod("node-idle", "10.0.0.1"), nodePod("node-busy", "10.0.0.2")},
+			status: testStatus(
+				testNode{uri: "http://10.0.0.1:5555", sessions: []string{""}},
+				testNode{uri: "http://10.0.0.2:5555", started: time.Hour, sessions: []string{"s1"}},
+			),
+			want: []string{"node-idle"},
+		},
+		{
+			name: "busy node registered by hostname keeps its pod",
+			pods: []corev1.Pod{nodePod("selenium-node-chrome-abc", "10.0.0.3")},
+			status: testStatus(
+				testNode{uri: "http://selenium-node-chrome-abc.selenium.svc:5555", started: time.Hour, sessions: []string{"s1"}},
+			),
+			resolveHostnames: true,
+			want:             nil,
+		},
+		{
+			name: "idle node registered by hostname is an orphan",
+			pods: []corev1.Pod{nodePod("selenium-node-chrome-abc", "10.0.0.3")},
+			status: testStatus(
+				testNode{uri: "http://selenium-node-chrome-abc.selenium.svc:5555", sessions: []string{""}},
+			),
+			resolveHostnames: true,
+			want:             []string{"selenium-node-chrome-abc"},
+		},
+		{
+			name: "hostname node is left alone without hostname resolution",
+			pods: []corev1.Pod{nodePod("selenium-node-chrome-abc", "10.0.0.3")},
+			status: testStatus(
+				testNode{uri: "http://selenium-node-chrome-abc.selenium.svc:5555", sessions: []string{""}},
+			),
+			want: nil,
+		},
+		{
+			name:   "node without slots is not orphaned",
+			pods:   []corev1.Pod{nodePod("node-a", "10.0.0.4")},
+			status: testStatus(testNode{uri: "http://10.0.0.4:5555"}),
+			want:   nil,
+		},
+		{
+			name:   "pod matching no node of the status is not orphaned",
+			pods:   []corev1.Pod{nodePod("node-a", "10.0.0.5")},
+			status: testStatus(testNode{uri: "http://10.0.0.1:5555", sessions: []string{""}}),
+			want:   nil,
+		},
+		{
+			name:   "pods younger than the min age are not orphaned",
+			pods:   []corev1.Pod{young},
+			status: testStatus(testNode{uri: "http://10.0.0.6:5555", sessions: []string{""}}),
+			want:   nil,
+		},
+		{
+			name:   "IPv6 node matches its pod",
+			pods:   []corev1.Pod{nodePod("node-v6", "fd00:0::7")},
+			status: testStatus(testNode{uri: "http://[fd00::7]:5555", sessions: []string{""}}),
+			want:   []string{"node-v6"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCleaner(newFakePodManager(tt.pods...))
+			c.SetResolveHostnames(tt.resolveHostnames)
+
+			orphans, err := c.FindOrphans(context.Background(), tt.status, "app=selenium-node", 30*time.Minute)
+			if err != nil {
+				t.Fatalf("FindOrphans() error = %v", err)
+			}
+			var got []string
+			for _, orphan := range orphans {
+				got = append(got, orphan.Name)
+				if want := testNow.Sub(orphan.Created); orphan.Age != want {
+					t.Errorf("Age of %s = %v, want %v at the cleaner's clock", orphan.Name, orphan.Age, want)
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindOrphans() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanOrphansKeepsPodsOfHostnameNodes(t *testing.T) {
+	client := newFakePodManager(nodePod("selenium-node-chrome-abc", "10.0.0.3"), nodePod("node-idle", "10.0.0.1"))
+	c := newTestCleaner(client)
+	c.SetResolveHostnames(true)
+	status := testStatus(
+		testNode{uri: "http://selenium-node-chrome-abc.selenium.svc:5555", started: time.Hour, sessions: []string{"s1"}},
+		testNode{uri: "http://10.0.0.1:5555", sessions: []string{""}},
+	)
+
+	if _, err := c.CleanOrphans(context.Background(), status, "app=selenium-node", 30*time.Minute); err != nil {
+		t.Fatalf("CleanOrphans() error = %v", err)
+	}
+	if got := client.deletedPods(); !slices.Equal(got, []string{"node-idle"}) {
+		t.Errorf("deleted pods = %v, want [node-idle]", got)
+	}
+}
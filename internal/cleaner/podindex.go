@@ -0,0 +1,123 @@
+package cleaner
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podIndex caches the pods of the searched namespaces for one cleanup run, so resolving
+// the pods of many sessions takes a single list call instead of one or more per session.
+// It is loaded on first use and reloaded at most once, when a lookup misses.
+type podIndex struct {
+	client       PodManager
+	sessionLabel string
+
+	mutex     sync.Mutex
+	loaded    bool
+	refreshed bool
+	byIP      map[string][]kubernetes.PodRef
+	bySession map[string]kubernetes.PodRef
+	pods      map[kubernetes.PodRef]*corev1.Pod
+}
+
+func newPodIndex(client PodManager, sessionLabel string) *podIndex {
+	return &podIndex{client: client, sessionLabel: sessionLabel}
+}
+
+// load lists the pods and rebuilds the maps. The caller holds the mutex.
+func (i *podIndex) load(ctx context.Context) error {
+	pods, err := i.client.ListPods(ctx)
+	if err != nil {
+		return err
+	}
+
+	i.byIP = make(map[string][]kubernetes.PodRef)
+	i.bySession = make(map[string]kubernetes.PodRef)
+	i.pods = make(map[kubernetes.PodRef]*corev1.Pod, len(pods))
+	for j := range pods {
+		pod := &pods[j]
+		ref := kubernetes.PodRef{Namespace: pod.Namespace, Name: pod.Name}
+		i.pods[ref] = pod
+		if ip := kubernetes.CanonicalIP(pod.Status.PodIP); ip != "" {
+			i.byIP[ip] = append(i.byIP[ip], ref)
+		}
+		if i.sessionLabel != "" && pod.Labels[i.sessionLabel] != "" {
+			i.bySession[pod.Labels[i.sessionLabel]] = ref
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == kubernetes.SessionIDEnv && env.Value != "" {
+					i.bySession[env.Value] = ref
+				}
+			}
+		}
+	}
+	i.loaded = true
+	return nil
+}
+
+// lookup runs find on the index, loading it first if needed and reloading it once when
+// find comes back empty
+func (i *podIndex) lookup(ctx context.Context, find func() []kubernetes.PodRef) ([]kubernetes.PodRef, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if !i.loaded {
+		if err := i.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if refs := find(); len(refs) > 0 || i.refreshed {
+		return refs, nil
+	}
+
+	i.refreshed = true
+	if err := i.load(ctx); err != nil {
+		return nil, err
+	}
+	return find(), nil
+}
+
+// podsByIP returns the pods with the given IP
+func (i *podIndex) podsByIP(ctx context.Context, podIP string) ([]kubernetes.PodRef, error) {
+	podIP = kubernetes.CanonicalIP(podIP)
+	return i.lookup(ctx, func() []kubernetes.PodRef {
+		return i.byIP[podIP]
+	})
+}
+
+// podsByName returns the pods whose name or hostname is the given DNS label. A label of
+// maximum length also matches longer pod names, which Kubernetes truncates to it for the
+// hostname.
+func (i *podIndex) podsByName(ctx context.Context, label string) ([]kubernetes.PodRef, error) {
+	return i.lookup(ctx, func() []kubernetes.PodRef {
+		var refs []kubernetes.PodRef
+		for ref, pod := range i.pods {
+			if pod.Name == label || pod.Spec.Hostname == label ||
+				(len(label) == maxHostnameLength && strings.HasPrefix(pod.Name, label)) {
+				refs = append(refs, ref)
+			}
+		}
+		return refs
+	})
+}
+
+// session returns the pod running the session, if the index knows it
+func (i *podIndex) session(sessionID string) (kubernetes.PodRef, bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	ref, ok := i.bySession[sessionID]
+	return ref, ok
+}
+
+// pod returns the listed pod, if the index knows it
+func (i *podIndex) pod(ref kubernetes.PodRef) (*corev1.Pod, bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	pod, ok := i.pods[ref]
+	return pod, ok
+}
@@ -0,0 +1,37 @@
+package cleaner
+
+import (
+	"context"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// PodManager is the part of the Kubernetes API the cleaner works with. *kubernetes.Client
+// implements it; a fake lets the cleanup logic run without a cluster.
+type PodManager interface {
+	// Pod lookup
+	GetPodsByIP(ctx context.Context, podIP string) ([]kubernetes.PodRef, error)
+	GetPodNameBySessionID(ctx context.Context, sessionID string) (kubernetes.PodRef, error)
+	GetPodNameBySessionIDLabeled(ctx context.Context, namespace, labelKey, sessionID string) (string, error)
+	GetPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error)
+	GetPodAnnotations(ctx context.Context, namespace, podName string) (map[string]string, error)
+	GetPodNodeName(ctx context.Context, namespace, podName string) (string, error)
+	ListPods(ctx context.Context) ([]corev1.Pod, error)
+	ListNodePods(ctx context.Context, labelSelector string) ([]corev1.Pod, error)
+
+	// Deletion
+	DeletePod(ctx context.Context, namespace, podName string, gracePeriod *int64) error
+	WatchPod(ctx context.Context, namespace, podName string) (watch.Interface, error)
+	RemovePodFinalizers(ctx context.Context, namespace, podName string) error
+
+	// Node scheduling
+	CordonNode(ctx context.Context, nodeName string) (bool, error)
+	UncordonNode(ctx context.Context, nodeName string) error
+
+	// Auditing
+	RecordPodEvent(ctx context.Context, namespace, podName, reason, message string) error
+}
+
+var _ PodManager = (*kubernetes.Client)(nil)
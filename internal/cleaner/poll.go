@@ -0,0 +1,64 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Polling intervals used to confirm deletions where watching pods is forbidden
+const (
+	pollInitialInterval = 500 * time.Millisecond
+	pollMaxInterval     = 10 * time.Second
+)
+
+// watchForbidden reports whether the error of a pod watch means the service account may
+// not watch pods. The first time it also logs that deletions are polled from now on.
+func (c *Cleaner) watchForbidden(err error) bool {
+	if !apierrors.IsForbidden(err) {
+		return false
+	}
+	if !c.pollDeletions.Swap(true) {
+		c.logger.Warn("Watching pods is forbidden, falling back to polling to confirm deletions", "error", err)
+	}
+	return true
+}
+
+// pollPodDeletion confirms a deletion by getting the pod with exponential backoff until
+// it is gone or timeout passes. Errors other than Forbidden are retried.
+func (c *Cleaner) pollPodDeletion(ctx context.Context, namespace, podName string, timeout time.Duration) error {
+	start := time.Now()
+	expired := time.After(timeout)
+	interval := pollInitialInterval
+	var lastErr error
+
+	for {
+		_, err := c.k8sClient.GetPod(ctx, namespace, podName)
+		switch {
+		case err == nil:
+		case apierrors.IsNotFound(err):
+			return nil
+		case apierrors.IsForbidden(err):
+			return fmt.Errorf("failed to poll pod %s: %w", podName, err)
+		default:
+			lastErr = err
+			c.logger.Debug("Failed to poll pod, retrying", "namespace", namespace, "pod", podName, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-expired:
+			if lastErr != nil {
+				return fmt.Errorf("timeout polling for pod %s deletion after %v (last error: %v): %w",
+					podName, time.Since(start).Round(time.Second), lastErr, ErrDeletionTimeout)
+			}
+			return fmt.Errorf("timeout polling for pod %s deletion after %v: %w",
+				podName, time.Since(start).Round(time.Second), ErrDeletionTimeout)
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, pollMaxInterval)
+	}
+}
@@ -0,0 +1,124 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SetGracefulQuit makes the cleaner ask the grid to end each session before its pod is deleted.
+// gridURL is the WebDriver base URL (e.g. http://localhost:4444/wd/hub), timeout bounds the
+// DELETE request and grace is how long to wait after a successful quit before deleting the pod.
+func (c *Cleaner) SetGracefulQuit(gridURL string, timeout, grace time.Duration) {
+	c.gracefulQuit = gridURL != ""
+	c.gridURL = strings.TrimSuffix(gridURL, "/")
+	c.quitTimeout = timeout
+	c.quitGrace = grace
+}
+
+// NodeForward makes the node of a session reachable, e.g. by port-forwarding to its pod. It
+// returns the base URL to send requests to in place of the node URI and a func releasing it.
+type NodeForward func(ctx context.Context, session SessionInfo) (baseURL string, release func(), err error)
+
+// SetQuitViaNode makes the graceful quit go to the node URI of the session instead of the
+// router, sparing the router when many sessions are quit at once. forward reaches the node
+// when its URI is not directly reachable, e.g. from outside the cluster; nil sends the quit
+// to the URI as is. A quit failing on the node is retried through the router.
+func (c *Cleaner) SetQuitViaNode(enabled bool, forward NodeForward) {
+	c.quitViaNode = enabled
+	c.nodeForward = forward
+}
+
+// SetHTTPClient sets the client used for requests to the grid, e.g. to trust a custom CA.
+// http.DefaultClient is used when unset.
+func (c *Cleaner) SetHTTPClient(client *http.Client) {
+	c.httpClient = client
+}
+
+// quitSession sends a WebDriver DELETE /session/{id} to the WebDriver base URL, the router's
+// or a node's
+func (c *Cleaner) quitSession(ctx context.Context, baseURL, sessionID string) error {
+	if c.quitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.quitTimeout)
+		defer cancel()
+	}
+
+	sessionURL := fmt.Sprintf("%s/session/%s", strings.TrimSuffix(baseURL, "/"), url.PathEscape(sessionID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, sessionURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build quit request: %w", err)
+	}
+
+	client := c.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("quit request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// quitSessionViaNode quits the session on its node, through the node forward if one is set.
+// The quit timeout bounds setting up the forward as well.
+func (c *Cleaner) quitSessionViaNode(ctx context.Context, session SessionInfo) error {
+	if session.URI == "" {
+		return fmt.Errorf("session has no node URI")
+	}
+	if c.quitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.quitTimeout)
+		defer cancel()
+	}
+
+	baseURL := session.URI
+	if c.nodeForward != nil {
+		forwarded, release, err := c.nodeForward(ctx, session)
+		if err != nil {
+			return fmt.Errorf("failed to reach node: %w", err)
+		}
+		defer release()
+		baseURL = forwarded
+	}
+	return c.quitSession(ctx, baseURL, session.SessionID)
+}
+
+// gracefulQuitSession quits the session through the grid and waits for the grace period.
+// With SetQuitViaNode the node is asked first and the router only when that fails.
+// Failures are only logged since the pod is deleted regardless.
+func (c *Cleaner) gracefulQuitSession(ctx context.Context, session SessionInfo) {
+	via := "router"
+	var err error
+	if c.quitViaNode {
+		via = "node"
+		if err = c.quitSessionViaNode(ctx, session); err != nil {
+			c.logger.Warn("Graceful quit through the node failed, falling back to the router",
+				"session_id", session.SessionID, "node_uri", session.URI, "error", err)
+		}
+	}
+	if !c.quitViaNode || err != nil {
+		via = "router"
+		err = c.quitSession(ctx, c.gridURL, session.SessionID)
+	}
+	if err != nil {
+		c.logger.Warn("Graceful quit failed, deleting pod anyway", "session_id", session.SessionID, "error", err)
+		return
+	}
+
+	c.logger.Info("Session quit through the grid, waiting before deleting its pod",
+		"session_id", session.SessionID, "via", via, "grace", c.quitGrace.String())
+	select {
+	case <-ctx.Done():
+	case <-time.After(c.quitGrace):
+	}
+}
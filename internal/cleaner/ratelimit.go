@@ -0,0 +1,29 @@
+package cleaner
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// SetDeleteRateLimit limits pod delete requests to qps per second with bursts of up to
+// burst requests, independent of the number of parallel workers. A qps of zero or less
+// removes the limit.
+func (c *Cleaner) SetDeleteRateLimit(qps float64, burst int) {
+	if qps <= 0 {
+		c.deleteLimiter = nil
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	c.deleteLimiter = rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// waitDeleteSlot blocks until the rate limit allows another delete request
+func (c *Cleaner) waitDeleteSlot(ctx context.Context) error {
+	if c.deleteLimiter == nil {
+		return nil
+	}
+	return c.deleteLimiter.Wait(ctx)
+}
@@ -0,0 +1,81 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodePod describes a node pod found in the cluster
+type NodePod struct {
+	Namespace string        // Kubernetes namespace of the pod
+	Name      string        // Kubernetes pod name
+	IP        string        // Pod IP address, in canonical form
+	Created   time.Time     // Pod creation time
+	Age       time.Duration // Pod age at the cleaner's clock, set by FindOrphans
+}
+
+func newNodePod(pod corev1.Pod) NodePod {
+	return NodePod{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		IP:        kubernetes.CanonicalIP(pod.Status.PodIP),
+		Created:   pod.CreationTimestamp.Time,
+	}
+}
+
+// Reconciliation compares the sessions reported by the grid with the node pods running in the cluster
+type Reconciliation struct {
+	Matched      []SessionInfo // Sessions backed by a node pod
+	Orphaned     []SessionInfo // Sessions whose pod could not be resolved
+	Unregistered []NodePod     // Node pods without any grid session
+}
+
+// Reconcile builds a read-only report of how grid sessions line up with the node pods
+// selected by labelSelector. Nothing is deleted.
+func (c *Cleaner) Reconcile(ctx context.Context, status *downloader.Status, labelSelector string) (*Reconciliation, error) {
+	sessions, err := c.parseSessionInfo(status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse session info: %w", err)
+	}
+
+	pods, err := c.k8sClient.ListNodePods(ctx, labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list node pods: %w", err)
+	}
+
+	// Pods are resolved like a cleanup run resolves them: by node IP, hostname or host ID,
+	// and by session ID when several pods match
+	c.pods = newPodIndex(c.k8sClient, c.sessionLabel)
+	defer func() { c.pods = nil }()
+
+	result := &Reconciliation{}
+	sessionPods := make(map[kubernetes.PodRef]bool, len(sessions))
+	for _, session := range sessions {
+		ref, err := c.getPodName(ctx, session)
+		if errors.Is(err, kubernetes.ErrPodNotFound) {
+			result.Orphaned = append(result.Orphaned, session)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the pod of session %s: %w", session.SessionID, err)
+		}
+		sessionPods[ref] = true
+		session.PodName = ref.Name
+		session.Namespace = ref.Namespace
+		result.Matched = append(result.Matched, session)
+	}
+
+	for _, pod := range pods {
+		if !sessionPods[kubernetes.PodRef{Namespace: pod.Namespace, Name: pod.Name}] {
+			result.Unregistered = append(result.Unregistered, newNodePod(pod))
+		}
+	}
+
+	return result, nil
+}
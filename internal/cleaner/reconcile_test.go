@@ -0,0 +1,82 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestReconcileMatchesNonCanonicalPodIPs(t *testing.T) {
+	client := newFakePodManager(
+		nodePod("node-v6", "fd00:0:0::1"),
+		nodePod("node-v4", "10.0.0.1"),
+		nodePod("node-idle", "10.0.0.2"),
+	)
+	c := newTestCleaner(client)
+	status := testStatus(
+		testNode{uri: "http://[fd00::1]:5555", started: time.Hour, sessions: []string{"s6"}},
+		testNode{uri: "http://10.0.0.1:5555", started: time.Hour, sessions: []string{"s4"}},
+		testNode{uri: "http://10.0.0.9:5555", started: time.Hour, sessions: []string{"lost"}},
+	)
+
+	report, err := c.Reconcile(context.Background(), status, "app=selenium-node")
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	matched := make(map[string]string)
+	for _, session := range report.Matched {
+		matched[session.SessionID] = session.PodName
+	}
+	if matched["s6"] != "node-v6" || matched["s4"] != "node-v4" || len(matched) != 2 {
+		t.Errorf("Matched = %v, want s6 on node-v6 and s4 on node-v4", matched)
+	}
+	if ids := sessionIDs(report.Orphaned); len(ids) != 1 || ids[0] != "lost" {
+		t.Errorf("Orphaned = %v, want [lost]", ids)
+	}
+	if len(report.Unregistered) != 1 || report.Unregistered[0].Name != "node-idle" {
+		t.Errorf("Unregistered = %v, want only node-idle", report.Unregistered)
+	}
+}
+
+func TestReconcileSharedNodeIP(t *testing.T) {
+	withSession := func(pod corev1.Pod, sessionID string) corev1.Pod {
+		pod.Spec.Containers = []corev1.Container{{
+			Name: "node",
+			Env:  []corev1.EnvVar{{Name: kubernetes.SessionIDEnv, Value: sessionID}},
+		}}
+		return pod
+	}
+	client := newFakePodManager(
+		withSession(nodePod("node-a", "10.0.0.1"), "sa"),
+		withSession(nodePod("node-b", "10.0.0.1"), "sb"),
+	)
+	c := newTestCleaner(client)
+	status := testStatus(testNode{uri: "http://10.0.0.1:5555", started: time.Hour, sessions: []string{"sa", "sb"}})
+
+	report, err := c.Reconcile(context.Background(), status, "app=selenium-node")
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	matched := make(map[string]string)
+	for _, session := range report.Matched {
+		matched[session.SessionID] = session.PodName
+	}
+	if matched["sa"] != "node-a" || matched["sb"] != "node-b" || len(matched) != 2 {
+		t.Errorf("Matched = %v, want sa on node-a and sb on node-b", matched)
+	}
+	if len(report.Orphaned) != 0 || len(report.Unregistered) != 0 {
+		t.Errorf("Orphaned = %v, Unregistered = %v, want none", sessionIDs(report.Orphaned), report.Unregistered)
+	}
+}
+
+func TestNewNodePodCanonicalizesIP(t *testing.T) {
+	pod := nodePod("node", "FD00:0::0001")
+	if got := newNodePod(pod).IP; got != "fd00::1" {
+		t.Errorf("newNodePod().IP = %q, want fd00::1", got)
+	}
+}
@@ -0,0 +1,55 @@
+package cleaner
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CleanupResult describes the outcome of a CleanPods run
+type CleanupResult struct {
+	Deleted        []string         // Names of the pods that were deleted
+	Sessions       []SessionInfo    // Sessions whose pods were deleted, in the order of Deleted
+	Skipped        []SessionInfo    // Sessions that were left alone (within limit, debounced or dry run)
+	Failed         map[string]error // Cleanup errors keyed by session ID
+	TimedOut       []string         // IDs of the failed sessions that hit the session timeout
+	FailedSessions []SessionInfo    // Sessions in Failed, in the order they failed
+	Nodes          NodeSummary      // Nodes of the status, and whether it was degraded
+	Refused        error            // Why the safety valve stopped the run, wrapping ErrTooManyDeletions; nil when it did not
+	StartTime      time.Time        // When the run started
+	Duration       time.Duration    // How long the run took
+
+	mutex sync.Mutex
+}
+
+func newCleanupResult() *CleanupResult {
+	return &CleanupResult{
+		Failed:    make(map[string]error),
+		StartTime: time.Now(),
+	}
+}
+
+func (r *CleanupResult) addDeleted(session SessionInfo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Deleted = append(r.Deleted, session.PodName)
+	r.Sessions = append(r.Sessions, session)
+}
+
+func (r *CleanupResult) addSkipped(session SessionInfo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Skipped = append(r.Skipped, session)
+}
+
+func (r *CleanupResult) addFailed(session SessionInfo, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Failed[session.SessionID] = err
+	r.FailedSessions = append(r.FailedSessions, session)
+	if errors.Is(err, ErrSessionTimeout) {
+		r.TimedOut = append(r.TimedOut, session.SessionID)
+		sort.Strings(r.TimedOut)
+	}
+}
@@ -0,0 +1,86 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// RetryPolicy controls how transient pod deletion errors are retried
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first one
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each following one
+
+	// Sleep waits between attempts. Defaults to a context-aware timer; tests can
+	// replace it to drive retries deterministically.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+	}
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isTransient reports whether an API error is worth retrying
+func isTransient(err error) bool {
+	return apierrors.IsTimeout(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTooManyRequests(err)
+}
+
+// SetRetryPolicy sets the retry policy used for pod deletion
+func (c *Cleaner) SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Sleep == nil {
+		policy.Sleep = sleepContext
+	}
+	c.retryPolicy = policy
+}
+
+// deletePodWithRetry deletes the pod, retrying transient API errors with exponential backoff.
+// It reports gone=true when the pod no longer exists, in which case there is nothing to wait for.
+func (c *Cleaner) deletePodWithRetry(ctx context.Context, namespace, podName string) (gone bool, err error) {
+	delay := c.retryPolicy.BaseDelay
+	for attempt := 1; ; attempt++ {
+		if err := c.waitDeleteSlot(ctx); err != nil {
+			return false, err
+		}
+		err = c.k8sClient.DeletePod(ctx, namespace, podName, c.gracePeriod)
+		switch {
+		case err == nil:
+			return false, nil
+		case apierrors.IsNotFound(err):
+			c.logger.Info("Pod is already gone", "namespace", namespace, "pod", podName)
+			return true, nil
+		case !isTransient(err) || attempt >= c.retryPolicy.MaxAttempts:
+			return false, fmt.Errorf("giving up after %d attempt(s): %w", attempt, err)
+		}
+
+		c.logger.Warn("Transient error deleting pod, retrying",
+			"pod", podName, "attempt", attempt, "max_attempts", c.retryPolicy.MaxAttempts, "delay", delay.String(), "error", err)
+		if err := c.retryPolicy.Sleep(ctx, delay); err != nil {
+			return false, err
+		}
+		delay *= 2
+	}
+}
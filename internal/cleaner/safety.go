@@ -0,0 +1,50 @@
+package cleaner
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyDeletions is wrapped by CleanupResult.Refused when the safety valve stops a bulk deletion
+var ErrTooManyDeletions = errors.New("too many sessions selected for deletion")
+
+// minBulkDeletion is the number of candidates from which the fraction limit applies, so a
+// small grid with a single stale session is not blocked
+const minBulkDeletion = 5
+
+// SetSafetyValve makes CleanPods refuse to delete anything when more than maxFraction of
+// all sessions, or more than maxCount sessions, exceeded their max age in one run. The
+// fraction only applies from minBulkDeletion candidates on. Zero disables either limit.
+func (c *Cleaner) SetSafetyValve(maxFraction float64, maxCount int) {
+	c.maxDeleteFraction = maxFraction
+	c.maxDeleteCount = maxCount
+}
+
+// safetyValveError returns an error wrapping ErrTooManyDeletions when the candidates of a
+// run exceed the safety limits
+func (c *Cleaner) safetyValveError(candidates, total int) error {
+	switch {
+	case c.maxDeleteCount > 0 && candidates > c.maxDeleteCount:
+		return fmt.Errorf("%w: %d of %d sessions, more than the limit of %d",
+			ErrTooManyDeletions, candidates, total, c.maxDeleteCount)
+	case c.maxDeleteFraction > 0 && candidates >= minBulkDeletion &&
+		float64(candidates) > c.maxDeleteFraction*float64(total):
+		return fmt.Errorf("%w: %d of %d sessions, more than %.0f%%",
+			ErrTooManyDeletions, candidates, total, c.maxDeleteFraction*100)
+	}
+	return nil
+}
+
+// checkSafetyValve returns the safetyValveError of a run. Dry runs are only warned about.
+func (c *Cleaner) checkSafetyValve(candidates, total int) error {
+	err := c.safetyValveError(candidates, total)
+	if err == nil {
+		return nil
+	}
+
+	if c.dryRun {
+		c.logger.Warn("Safety valve would stop this run", "error", err)
+		return nil
+	}
+	return err
+}
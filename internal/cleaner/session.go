@@ -0,0 +1,46 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+)
+
+// CleanSession cleans up one session by ID regardless of its age. The pod is found by the
+// session ID in its container environment; protection, debouncing, dry run, graceful quit,
+// deletion and confirmation then apply as in CleanPods. It reports whether the pod was
+// deleted; a session without a pod yields an error wrapping kubernetes.ErrPodNotFound.
+func (c *Cleaner) CleanSession(ctx context.Context, sessionID string) (bool, error) {
+	pod, err := c.k8sClient.GetPodNameBySessionID(ctx, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to find pod of session %s: %w", sessionID, err)
+	}
+
+	session := SessionInfo{SessionID: sessionID, PodName: pod.Name, Namespace: pod.Namespace}
+	// Without the grid status the pod's creation stands in for the session start
+	if p, err := c.k8sClient.GetPod(ctx, pod.Namespace, pod.Name); err == nil {
+		session.StartTime = p.CreationTimestamp.Time
+		session.NodeIP = p.Status.PodIP
+		session.Age = c.sessionAge(session)
+	}
+	c.emit(PhaseParsed, session, nil)
+
+	deleted, err := c.cleanupSessionTimed(ctx, &session)
+	switch {
+	case err != nil:
+		c.stats.addFailure()
+		c.emit(PhaseFailed, session, err)
+		return false, err
+	case deleted:
+		c.stats.addDeleted()
+		c.emit(PhaseDeleted, session, nil)
+	default:
+		c.emit(PhaseSkipped, session, nil)
+	}
+
+	if c.deletions != nil && !c.dryRun {
+		if err := c.deletions.save(c.now()); err != nil {
+			c.logger.Warn("Failed to save deletion log", "error", err)
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,36 @@
+package cleaner
+
+import (
+	"context"
+	"time"
+)
+
+// SetShutdownGrace lets session cleanups that are already running continue for up to grace
+// once the context passed to CleanPods is cancelled, so a deletion interrupted by a signal
+// can still be confirmed. No new cleanups start after the cancellation. Zero stops them
+// immediately.
+func (c *Cleaner) SetShutdownGrace(grace time.Duration) {
+	c.shutdownGrace = grace
+}
+
+// graceContext returns the context for in-flight session cleanups. It is cancelled
+// shutdownGrace after ctx, or when the returned cancel function is called.
+func (c *Cleaner) graceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.shutdownGrace <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		c.logger.Warn("Shutting down, giving in-flight cleanups time to finish", "grace", c.shutdownGrace.String())
+		select {
+		case <-time.After(c.shutdownGrace):
+			cancel()
+		case <-graceCtx.Done():
+		}
+	})
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
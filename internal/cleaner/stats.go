@@ -0,0 +1,64 @@
+package cleaner
+
+import (
+	"sync"
+	"time"
+)
+
+// CleanupStats accumulates counters over every CleanPods run of a Cleaner.
+// The counters only ever grow, so they map directly onto Prometheus counters.
+// All methods are safe for concurrent use.
+type CleanupStats struct {
+	mutex sync.Mutex
+	snap  StatsSnapshot
+}
+
+// StatsSnapshot is a point-in-time copy of the cleanup counters
+type StatsSnapshot struct {
+	Runs             int64         // CleanPods runs completed
+	SessionsSeen     int64         // Sessions found in the grid status
+	SessionsExpired  int64         // Sessions that exceeded their max age
+	PodsDeleted      int64         // Pods deleted
+	DeletionFailures int64         // Sessions whose cleanup failed
+	CleanupDuration  time.Duration // Total time spent in CleanPods
+	LastRun          time.Time     // When the last run finished
+}
+
+// Snapshot returns a copy of the current counters
+func (s *CleanupStats) Snapshot() StatsSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.snap
+}
+
+func (s *CleanupStats) addSeen(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snap.SessionsSeen += int64(n)
+}
+
+func (s *CleanupStats) addExpired() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snap.SessionsExpired++
+}
+
+func (s *CleanupStats) addDeleted() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snap.PodsDeleted++
+}
+
+func (s *CleanupStats) addFailure() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snap.DeletionFailures++
+}
+
+func (s *CleanupStats) finishRun(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snap.Runs++
+	s.snap.CleanupDuration += d
+	s.snap.LastRun = time.Now()
+}
@@ -0,0 +1,39 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultSessionTimeout bounds the cleanup of a single session
+const defaultSessionTimeout = 3 * time.Minute
+
+// ErrSessionTimeout is wrapped in the cleanup error of a session whose cleanup took longer
+// than the session timeout
+var ErrSessionTimeout = errors.New("session cleanup timed out")
+
+// SetSessionTimeout bounds how long the cleanup of a single session may take, including
+// the deletion confirmation, so a pod stuck in Terminating frees its worker for the other
+// sessions. Zero disables the bound.
+func (c *Cleaner) SetSessionTimeout(timeout time.Duration) {
+	c.sessionTimeout = timeout
+}
+
+// cleanupSessionTimed runs cleanupSession bounded by the session timeout. A cleanup cut
+// short by it fails with an error wrapping ErrSessionTimeout.
+func (c *Cleaner) cleanupSessionTimed(ctx context.Context, session *SessionInfo) (bool, error) {
+	if c.sessionTimeout <= 0 {
+		return c.cleanupSession(ctx, session)
+	}
+
+	sessionCtx, cancel := context.WithTimeoutCause(ctx, c.sessionTimeout, ErrSessionTimeout)
+	defer cancel()
+
+	deleted, err := c.cleanupSession(sessionCtx, session)
+	if err != nil && errors.Is(context.Cause(sessionCtx), ErrSessionTimeout) {
+		err = fmt.Errorf("%w after %v: %w", ErrSessionTimeout, c.sessionTimeout, err)
+	}
+	return deleted, err
+}
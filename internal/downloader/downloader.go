@@ -2,12 +2,17 @@
 package downloader
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -17,30 +22,123 @@ const (
 	permissions  = 0644
 )
 
+// snapshotLayout is the UTC timestamp prefix of snapshot file names
+const snapshotLayout = "20060102-150405"
+
+// DataDirEnv is the environment variable overriding the data directory
+const DataDirEnv = "SELENIUM_CLEANER_DATA_DIR"
+
+// ErrStatusDownload is wrapped by the errors of requests for the grid status that failed,
+// as opposed to responses that could not be parsed
+var ErrStatusDownload = errors.New("failed to download status")
+
+// Status is the grid status normalized to the Grid 4 slot layout, whatever schema the
+// grid actually reported
 type Status struct {
 	Value struct {
 		Message string `json:"message"`
-		Nodes   []struct {
-			ID        string `json:"id"`
-			URI       string `json:"uri"`
-			Slots     []struct {
-				ID      struct {
-					HostID string `json:"hostId"`
-					ID     string `json:"id"`
-				} `json:"id"`
-				LastStarted string `json:"lastStarted"`
-				Session     struct {
-					SessionID  string `json:"sessionId"`
-					Start     string `json:"start"`
-					URI       string `json:"uri"`
-				} `json:"session"`
-			} `json:"slots"`
-		} `json:"nodes"`
+		Nodes   []Node `json:"nodes"`
 	} `json:"value"`
+
+	// Schema is the status schema the document was detected as
+	Schema Schema `json:"-"`
+
+	// ClockOffset is how far the grid's clock is ahead of the local one, from the Date
+	// header of the response. It is zero when unknown, e.g. for a status read from a file.
+	ClockOffset time.Duration `json:"-"`
+
+	// FetchedAt is when the status was downloaded, the timestamp of its snapshot file. It is
+	// zero for a status read from a file.
+	FetchedAt time.Time `json:"-"`
+}
+
+// Node is a grid node with its slots
+type Node struct {
+	ID           string `json:"id"`
+	URI          string `json:"uri"`
+	Availability string `json:"availability"` // UP, DRAINING or DOWN
+	Slots        []Slot `json:"slots"`
+}
+
+// Slot is a node slot, holding a session while one is running
+type Slot struct {
+	ID struct {
+		HostID string `json:"hostId"`
+		ID     string `json:"id"`
+	} `json:"id"`
+	LastStarted string       `json:"lastStarted"`
+	Stereotype  Capabilities `json:"stereotype"`
+	Session     Session      `json:"session"`
+}
+
+// Session is the session running in a slot
+type Session struct {
+	SessionID    string       `json:"sessionId"`
+	Start        string       `json:"start"`
+	URI          string       `json:"uri"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Capabilities are the WebDriver capabilities of a slot stereotype or a session
+type Capabilities struct {
+	BrowserName  string `json:"browserName"`
+	PlatformName string `json:"platformName"`
+}
+
+// Options controls how the status is downloaded
+type Options struct {
+	Timeout    time.Duration // Timeout of a single request, 0 means no timeout
+	Attempts   int           // Total attempts including the first one
+	RetryDelay time.Duration // Delay before the first retry, doubled for each following one
+
+	Headers     map[string]string // Extra request headers, e.g. for an auth proxy
+	BearerToken string            // Sent as "Authorization: Bearer <token>" when set
+
+	BasicAuthUser     string // HTTP basic auth user, used when set
+	BasicAuthPassword string // HTTP basic auth password
+
+	InsecureSkipVerify bool   // Skip TLS certificate verification of HTTPS grids
+	CACertFile         string // PEM file with extra CA certificates trusted for HTTPS grids
+
+	HTTPClient *http.Client // Client for all requests, overriding Timeout and the TLS settings; built per request when nil
+
+	RetainCount int           // Keep at most this many status snapshots, 0 keeps all
+	RetainAge   time.Duration // Remove snapshots older than this, 0 keeps all
+
+	DataDir  string // Directory for status snapshots, see getDataDir for the fallbacks
+	InMemory bool   // Parse the response body directly without writing snapshots to disk
+
+	Logger *slog.Logger // Logger for retries and warnings, slog.Default() when nil
+}
+
+// logger returns the configured logger or the default one
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.Default()
+	}
+	return o.Logger
+}
+
+// DefaultOptions returns the download options used by the CLI unless overridden
+func DefaultOptions() Options {
+	return Options{
+		Timeout:    30 * time.Second,
+		Attempts:   3,
+		RetryDelay: time.Second,
+	}
 }
 
-// getDataDir returns the path to the data directory
-func getDataDir() (string, error) {
+// getDataDir returns the path to the data directory. An explicit override wins over the
+// SELENIUM_CLEANER_DATA_DIR environment variable, which wins over the data folder next to
+// the executable.
+func getDataDir(override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if dir := os.Getenv(DataDirEnv); dir != "" {
+		return dir, nil
+	}
+
 	// Get the executable's directory
 	execPath, err := os.Executable()
 	if err != nil {
@@ -55,90 +153,228 @@ func getDataDir() (string, error) {
 }
 
 // ensureDataDir creates the data directory if it doesn't exist
-func ensureDataDir() (string, error) {
-	dataDir, err := getDataDir()
+func ensureDataDir(override string) (string, error) {
+	dataDir, err := getDataDir(override)
 	if err != nil {
 		return "", err
 	}
 
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create data directory: %w", err)
+		return "", fmt.Errorf("failed to create data directory %s: %w", dataDir, err)
 	}
 	return dataDir, nil
 }
 
-// downloadFile downloads the status from the given URL and saves it to the data directory
-func downloadFile(url string) (string, error) {
-	dataDir, err := ensureDataDir()
+// SnapshotName returns the timestamped file name of a snapshot taken at t, e.g.
+// 20240101-120000-status.json for name status.json
+func SnapshotName(t time.Time, name string) string {
+	return fmt.Sprintf("%s-%s", t.UTC().Format(snapshotLayout), name)
+}
+
+// DataDir returns the data directory used for status snapshots, creating it if needed.
+// override takes precedence over the environment and the default location.
+func DataDir(override string) (string, error) {
+	return ensureDataDir(override)
+}
+
+// fetch performs the GET request, retrying connection errors and 5xx responses with
+// exponential backoff. 4xx responses are returned as errors immediately.
+func fetch(ctx context.Context, url string, opts Options) (*http.Response, error) {
+	return send(ctx, http.MethodGet, url, nil, opts)
+}
+
+// send performs the request with the given JSON body (nil for none), with the retries
+// described on fetch
+func send(ctx context.Context, method, url string, body []byte, opts Options) (*http.Response, error) {
+	client, err := NewHTTPClient(opts)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	attempts := max(opts.Attempts, 1)
+	delay := opts.RetryDelay
+
+	for attempt := 1; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for name, value := range opts.Headers {
+			req.Header.Set(name, value)
+		}
+		if opts.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+		}
+		if opts.BasicAuthUser != "" {
+			req.SetBasicAuth(opts.BasicAuthUser, opts.BasicAuthPassword)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode == http.StatusOK {
+			if err := decompress(resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		retryable := true
+		if err != nil {
+			err = fmt.Errorf("http %s error: %w", strings.ToLower(method), err)
+		} else {
+			resp.Body.Close()
+			err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+			retryable = resp.StatusCode >= 500
+		}
+
+		if !retryable || attempt >= attempts || ctx.Err() != nil {
+			return nil, err
+		}
+
+		opts.logger().Warn("Status download failed, retrying",
+			"attempt", attempt, "max_attempts", attempts, "delay", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
+}
 
-	resp, err := http.Get(url)
+// Fetch downloads the status document from the URL and returns it without saving it,
+// after checking that it is valid JSON
+func Fetch(ctx context.Context, url string, opts Options) ([]byte, error) {
+	resp, err := fetch(ctx, url, opts)
 	if err != nil {
-		return "", fmt.Errorf("http get error: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrStatusDownload, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status response: %w", err)
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("status from %s is not valid JSON", url)
 	}
+	return data, nil
+}
+
+// clockOffset estimates how far the server's clock is ahead of the local one from the
+// response's Date header, which has a resolution of one second. Zero means unknown.
+func clockOffset(resp *http.Response) time.Duration {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+	return date.Sub(time.Now().Truncate(time.Second))
+}
+
+// downloadFile downloads the status from the given URL and saves it to the data directory,
+// named after fetchedAt. It also returns the clock offset of the server.
+func downloadFile(ctx context.Context, url string, opts Options, fetchedAt time.Time) (string, time.Duration, error) {
+	dataDir, err := ensureDataDir(opts.DataDir)
+	if err != nil {
+		return "", 0, err
+	}
+
+	resp, err := fetch(ctx, url, opts)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrStatusDownload, err)
+	}
+	defer resp.Body.Close()
+	offset := clockOffset(resp)
 
 	// Create a timestamped filename
-	timestamp := time.Now().UTC().Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s", timestamp, statusFile)
-	filePath := filepath.Join(dataDir, filename)
+	filePath := filepath.Join(dataDir, SnapshotName(fetchedAt, statusFile))
 
 	// Create the file
 	file, err := os.Create(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return "", 0, fmt.Errorf("failed to create file: %w", err)
 	}
 	defer file.Close()
 
 	// Copy the response body to the file
 	if _, err := io.Copy(file, resp.Body); err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+		return "", 0, fmt.Errorf("failed to write file: %w", err)
 	}
 
 	// Create/Update symlink to latest status file
 	latestLink := filepath.Join(dataDir, statusFile)
 	_ = os.Remove(latestLink) // Remove existing symlink if it exists
 	if err := os.Symlink(filePath, latestLink); err != nil {
-		return "", fmt.Errorf("failed to create symlink: %w", err)
+		return "", 0, fmt.Errorf("failed to create symlink: %w", err)
+	}
+
+	if err := pruneStatusFiles(opts.logger(), dataDir, opts.RetainCount, opts.RetainAge); err != nil {
+		opts.logger().Warn("Failed to prune old status files", "error", err)
 	}
 
-	return filePath, nil
+	return filePath, offset, nil
 }
 
-// parseStatusFile reads and parses the status file
-func parseStatusFile(filePath string) (*Status, error) {
+// ParseStatusFile reads and parses a saved status file
+func ParseStatusFile(filePath string) (*Status, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read status file: %w", err)
 	}
 
-	var status Status
-	if err := json.Unmarshal(data, &status); err != nil {
-		return nil, fmt.Errorf("failed to parse status file: %w", err)
+	return parseStatus(data)
+}
+
+// fetchStatusInMemory downloads and parses the status without touching the disk
+func fetchStatusInMemory(ctx context.Context, url string, opts Options) (*Status, error) {
+	fetchedAt := time.Now()
+	resp, err := fetch(ctx, url, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStatusDownload, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status response: %w", err)
 	}
 
-	return &status, nil
+	status, err := parseStatus(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse status: %w", err)
+	}
+	status.ClockOffset = clockOffset(resp)
+	status.FetchedAt = fetchedAt
+	return status, nil
 }
 
-// DownloadStatus downloads the status from the URL, saves it to a file, and returns the parsed status
-func DownloadStatus(url string) (*Status, error) {
+// DownloadStatus downloads the status from the URL, saves it to a file, and returns the parsed status.
+// With opts.InMemory the response is parsed directly and nothing is written.
+func DownloadStatus(ctx context.Context, url string, opts Options) (*Status, error) {
+	if opts.InMemory {
+		return fetchStatusInMemory(ctx, url, opts)
+	}
+
 	// Download and save the file
-	filePath, err := downloadFile(url)
+	fetchedAt := time.Now()
+	filePath, offset, err := downloadFile(ctx, url, opts, fetchedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download status: %w", err)
+		return nil, err
 	}
 
 	// Parse the saved file
-	status, err := parseStatusFile(filePath)
+	status, err := ParseStatusFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse status: %w", err)
 	}
+	status.ClockOffset = offset
+	status.FetchedAt = fetchedAt
 
 	return status, nil
 }
@@ -0,0 +1,170 @@
+package downloader_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader/downloadertest"
+)
+
+// slotsStatus is a Grid 4.8+ status with one node running one session
+const slotsStatus = `{"value": {"ready": true, "message": "Selenium Grid ready.", "nodes": [{
+  "id": "node-1", "uri": "http://10.0.0.1:5555", "availability": "UP",
+  "slots": [{"id": {"hostId": "node-1", "id": "slot-1"}, "lastStarted": "2025-06-01T10:00:00Z",
+    "stereotype": {"browserName": "chrome", "platformName": "linux"},
+    "session": {"sessionId": "s1", "start": "2025-06-01T10:00:00Z", "uri": "http://10.0.0.1:5555",
+      "capabilities": {"browserName": "chrome"}}}]}]}}`
+
+// sessionsStatus is an older Grid 4 status listing sessions under the node
+const sessionsStatus = `{"value": {"ready": true, "message": "Selenium Grid ready.", "nodes": [{
+  "id": "node-1", "uri": "http://10.0.0.1:5555", "availability": "UP",
+  "sessions": [{"sessionId": "s1", "start": "2025-06-01T10:00:00Z",
+    "stereotype": {"browserName": "chrome"}, "currentCapabilities": {"platformName": "linux"}}]}]}}`
+
+// discardLogger silences the retry warnings of the tests
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testOptions returns download options for a fake grid: in memory, fast retries and no logs
+func testOptions() downloader.Options {
+	opts := downloader.DefaultOptions()
+	opts.RetryDelay = time.Millisecond
+	opts.InMemory = true
+	opts.Logger = discardLogger
+	return opts
+}
+
+func TestDownloadStatusRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		code         int
+		attempts     int
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "no failure", attempts: 3, wantRequests: 1},
+		{name: "server errors are retried", failures: 2, code: http.StatusServiceUnavailable, attempts: 3, wantRequests: 3},
+		{name: "retries run out", failures: 3, code: http.StatusInternalServerError, attempts: 2, wantErr: true, wantRequests: 2},
+		{name: "client errors are not retried", failures: 1, code: http.StatusNotFound, attempts: 3, wantErr: true, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid := downloadertest.NewFakeGrid(slotsStatus, downloadertest.WithFailures(tt.failures, tt.code))
+			defer grid.Close()
+			opts := testOptions()
+			opts.Attempts = tt.attempts
+
+			status, err := downloader.DownloadStatus(context.Background(), grid.StatusURL(), opts)
+			if tt.wantErr {
+				if !errors.Is(err, downloader.ErrStatusDownload) {
+					t.Errorf("DownloadStatus() error = %v, want ErrStatusDownload", err)
+				}
+			} else if err != nil {
+				t.Fatalf("DownloadStatus() error = %v", err)
+			} else if len(status.Value.Nodes) != 1 {
+				t.Errorf("DownloadStatus() nodes = %d, want 1", len(status.Value.Nodes))
+			}
+			if got := grid.Requests(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestDownloadStatusTimeout(t *testing.T) {
+	grid := downloadertest.NewFakeGrid(slotsStatus, downloadertest.WithLatency(time.Second))
+	defer grid.Close()
+	opts := testOptions()
+	opts.Timeout = 50 * time.Millisecond
+	opts.Attempts = 2
+
+	start := time.Now()
+	_, err := downloader.DownloadStatus(context.Background(), grid.StatusURL(), opts)
+	if !errors.Is(err, downloader.ErrStatusDownload) {
+		t.Fatalf("DownloadStatus() error = %v, want ErrStatusDownload", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("DownloadStatus() took %v, want it to give up after the request timeouts", elapsed)
+	}
+	if got := grid.Requests(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestDownloadStatusSchemas(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantSchema downloader.Schema
+		wantErr    string
+	}{
+		{name: "slots", status: slotsStatus, wantSchema: downloader.SchemaSlots},
+		{name: "sessions", status: sessionsStatus, wantSchema: downloader.SchemaSessions},
+		{name: "empty grid", status: `{"value": {"ready": false, "nodes": []}}`, wantSchema: downloader.SchemaSlots},
+		{name: "HTML page", status: `<html><body>Not the grid</body></html>`, wantErr: "not a JSON object"},
+		{name: "missing nodes", status: `{"value": {"ready": true}}`, wantErr: "missing value.nodes"},
+		{name: "unknown node layout", status: `{"value": {"nodes": [{"id": "node-1"}]}}`, wantErr: "unrecognized status schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid := downloadertest.NewFakeGrid(tt.status)
+			defer grid.Close()
+
+			status, err := downloader.DownloadStatus(context.Background(), grid.StatusURL(), testOptions())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DownloadStatus() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DownloadStatus() error = %v", err)
+			}
+			if status.Schema != tt.wantSchema {
+				t.Errorf("Schema = %s, want %s", status.Schema, tt.wantSchema)
+			}
+			for _, node := range status.Value.Nodes {
+				for _, slot := range node.Slots {
+					if slot.Session.SessionID != "s1" || slot.Stereotype.BrowserName != "chrome" {
+						t.Errorf("slot = %+v, want session s1 on chrome", slot)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadStatusSavesSnapshot(t *testing.T) {
+	grid := downloadertest.NewFakeGrid(slotsStatus)
+	defer grid.Close()
+	opts := testOptions()
+	opts.InMemory = false
+	opts.DataDir = t.TempDir()
+
+	status, err := downloader.DownloadStatus(context.Background(), grid.StatusURL(), opts)
+	if err != nil {
+		t.Fatalf("DownloadStatus() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(opts.DataDir, downloader.SnapshotName(status.FetchedAt, "status.json")))
+	if err != nil {
+		t.Fatalf("reading the snapshot: %v", err)
+	}
+	if string(data) != slotsStatus {
+		t.Errorf("snapshot = %q, want the served status", data)
+	}
+	if _, err := os.Readlink(filepath.Join(opts.DataDir, "status.json")); err != nil {
+		t.Errorf("latest status link: %v", err)
+	}
+}
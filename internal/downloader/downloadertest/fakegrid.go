@@ -0,0 +1,143 @@
+// Package downloadertest serves canned grid status documents over HTTP, so the status
+// download and the cleanup on top of it can be exercised without a grid or a cluster.
+package downloadertest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/gridconn"
+)
+
+// DefaultStatusPath is the path the fake grid serves the status at unless WithPath is given,
+// the same as the cleaner's default
+const DefaultStatusPath = gridconn.DefaultStatusPath
+
+// FakeGrid is a test HTTP server answering the status path with a canned document. Other
+// paths get 404.
+type FakeGrid struct {
+	*httptest.Server
+
+	path     string
+	latency  time.Duration
+	gzip     bool
+	failures int // requests left to fail with failCode
+	failCode int
+
+	mutex    sync.Mutex
+	status   []byte
+	requests atomic.Int64
+}
+
+// Option configures a FakeGrid
+type Option func(*FakeGrid)
+
+// WithPath serves the status at path instead of DefaultStatusPath
+func WithPath(path string) Option {
+	return func(g *FakeGrid) { g.path = path }
+}
+
+// WithLatency delays every response by d, or until the request is cancelled
+func WithLatency(d time.Duration) Option {
+	return func(g *FakeGrid) { g.latency = d }
+}
+
+// WithFailures answers the first n requests with the HTTP status code instead of the status
+func WithFailures(n, code int) Option {
+	return func(g *FakeGrid) { g.failures, g.failCode = n, code }
+}
+
+// WithGzip gzip-encodes the status whether or not the client asked for it, like some proxies do
+func WithGzip() Option {
+	return func(g *FakeGrid) { g.gzip = true }
+}
+
+// NewFakeGrid starts a fake grid serving status, which is sent as is when it is a []byte or
+// a string and encoded as JSON otherwise, e.g. a downloader.Status. An empty grid needs
+// Value.Nodes set to an empty slice, as a null node list is rejected. Close it when done.
+func NewFakeGrid(status interface{}, opts ...Option) *FakeGrid {
+	g := &FakeGrid{path: DefaultStatusPath}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if err := g.SetStatus(status); err != nil {
+		panic(err)
+	}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	return g
+}
+
+// SetStatus replaces the served status document, see NewFakeGrid
+func (g *FakeGrid) SetStatus(status interface{}) error {
+	var data []byte
+	switch s := status.(type) {
+	case []byte:
+		data = s
+	case string:
+		data = []byte(s)
+	default:
+		var err error
+		if data, err = json.Marshal(status); err != nil {
+			return fmt.Errorf("failed to encode status: %w", err)
+		}
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.status = data
+	return nil
+}
+
+// StatusURL returns the URL of the status endpoint
+func (g *FakeGrid) StatusURL() string {
+	return g.URL + g.path
+}
+
+// Requests returns how many requests the status path received, failed ones included
+func (g *FakeGrid) Requests() int {
+	return int(g.requests.Load())
+}
+
+func (g *FakeGrid) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != g.path {
+		http.NotFound(w, r)
+		return
+	}
+	n := g.requests.Add(1)
+
+	if g.latency > 0 {
+		select {
+		case <-time.After(g.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if n <= int64(g.failures) {
+		http.Error(w, http.StatusText(g.failCode), g.failCode)
+		return
+	}
+
+	g.mutex.Lock()
+	data := g.status
+	g.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if g.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		data = buf.Bytes()
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,150 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// nodesQuery asks the grid for every node with its running sessions
+const nodesQuery = `{
+  nodesInfo {
+    nodes {
+      id
+      uri
+      status
+      sessions {
+        id
+        uri
+        capabilities
+        sessionDurationMillis
+        slot {
+          id
+          stereotype
+          lastStarted
+        }
+      }
+    }
+  }
+}`
+
+// graphQLResponse is the answer to nodesQuery
+type graphQLResponse struct {
+	Data struct {
+		NodesInfo struct {
+			Nodes []graphQLNode `json:"nodes"`
+		} `json:"nodesInfo"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// graphQLNode is a node as returned by the GraphQL endpoint. Capabilities and stereotypes
+// are JSON documents encoded as strings.
+type graphQLNode struct {
+	ID       string `json:"id"`
+	URI      string `json:"uri"`
+	Status   string `json:"status"`
+	Sessions []struct {
+		ID                    string      `json:"id"`
+		URI                   string      `json:"uri"`
+		Capabilities          string      `json:"capabilities"`
+		SessionDurationMillis graphQLLong `json:"sessionDurationMillis"`
+		Slot                  struct {
+			ID          string `json:"id"`
+			Stereotype  string `json:"stereotype"`
+			LastStarted string `json:"lastStarted"`
+		} `json:"slot"`
+	} `json:"sessions"`
+}
+
+// graphQLLong is a GraphQL Long. The grid sends it as a JSON number, but some versions
+// and proxies quote it, so a string holding a number is accepted too. Anything else leaves
+// it invalid rather than failing the whole response, like an undecodable capability.
+type graphQLLong struct {
+	Value int64
+	Valid bool // false when the field was null, absent or not a number
+}
+
+func (l *graphQLLong) UnmarshalJSON(data []byte) error {
+	*l = graphQLLong{}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return nil
+	}
+	if value, err := strconv.ParseInt(number.String(), 10, 64); err == nil {
+		*l = graphQLLong{Value: value, Valid: true}
+	}
+	return nil
+}
+
+// FetchGraphQL queries the grid's GraphQL endpoint for its nodes and sessions and maps the
+// answer into Status, so it can stand in for the REST status. Nothing is written to disk.
+func FetchGraphQL(ctx context.Context, url string, opts Options) (*Status, error) {
+	body, err := json.Marshal(map[string]string{"query": nodesQuery})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GraphQL query: %w", err)
+	}
+
+	fetchedAt := time.Now()
+	resp, err := send(ctx, http.MethodPost, url, body, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to query GraphQL: %w", ErrStatusDownload, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GraphQL response: %w", err)
+	}
+
+	var answer graphQLResponse
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse GraphQL response: %w", err)
+	}
+	if len(answer.Errors) > 0 {
+		return nil, fmt.Errorf("GraphQL query failed: %s", answer.Errors[0].Message)
+	}
+
+	// Session starts are derived in the grid's clock, like the timestamps of /status
+	offset := clockOffset(resp)
+	now := time.Now().UTC().Add(offset)
+	status := &Status{Schema: SchemaGraphQL, ClockOffset: offset, FetchedAt: fetchedAt}
+	for _, node := range answer.Data.NodesInfo.Nodes {
+		status.Value.Nodes = append(status.Value.Nodes, node.normalize(now))
+	}
+	return status, nil
+}
+
+// normalize converts a GraphQL node into the slot layout, one slot per running session.
+// The session start is derived from its duration, the GraphQL start time being a local
+// timestamp without a zone.
+func (n *graphQLNode) normalize(now time.Time) Node {
+	node := Node{ID: n.ID, URI: n.URI, Availability: n.Status}
+	for _, session := range n.Sessions {
+		var slot Slot
+		slot.ID.HostID = n.ID
+		slot.ID.ID = session.Slot.ID
+		slot.LastStarted = session.Slot.LastStarted
+		slot.Session.SessionID = session.ID
+		slot.Session.URI = session.URI
+		if slot.Session.URI == "" {
+			slot.Session.URI = n.URI
+		}
+
+		if duration := session.SessionDurationMillis; duration.Valid {
+			slot.Session.Start = now.Add(-time.Duration(duration.Value) * time.Millisecond).Format(time.RFC3339Nano)
+		}
+
+		// Capabilities and stereotypes that fail to decode just leave the browser empty
+		_ = json.Unmarshal([]byte(session.Slot.Stereotype), &slot.Stereotype)
+		_ = json.Unmarshal([]byte(session.Capabilities), &slot.Session.Capabilities)
+		node.Slots = append(node.Slots, slot)
+	}
+	return node
+}
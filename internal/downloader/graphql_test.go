@@ -0,0 +1,62 @@
+package downloader_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader/downloadertest"
+)
+
+func TestFetchGraphQLSessionDuration(t *testing.T) {
+	tests := []struct {
+		name      string
+		duration  string // raw JSON of sessionDurationMillis
+		wantStart bool
+	}{
+		{name: "number", duration: `5400000`, wantStart: true},
+		{name: "string", duration: `"5400000"`, wantStart: true},
+		{name: "null", duration: `null`},
+		{name: "not a number", duration: `"soon"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid := downloadertest.NewFakeGrid(`{"data": {"nodesInfo": {"nodes": [{
+  "id": "node-1", "uri": "http://10.0.0.1:5555", "status": "UP",
+  "sessions": [{"id": "s1", "uri": "http://10.0.0.1:5555",
+    "capabilities": "{\"browserName\": \"chrome\"}",
+    "sessionDurationMillis": `+tt.duration+`,
+    "slot": {"id": "slot-1", "stereotype": "{\"browserName\": \"chrome\"}", "lastStarted": ""}}]}]}}}`,
+				downloadertest.WithPath("/graphql"))
+			defer grid.Close()
+
+			status, err := downloader.FetchGraphQL(context.Background(), grid.StatusURL(), testOptions())
+			if err != nil {
+				t.Fatalf("FetchGraphQL() error = %v", err)
+			}
+			if len(status.Value.Nodes) != 1 || len(status.Value.Nodes[0].Slots) != 1 {
+				t.Fatalf("FetchGraphQL() nodes = %+v, want one node with one slot", status.Value.Nodes)
+			}
+			slot := status.Value.Nodes[0].Slots[0]
+			if slot.Session.SessionID != "s1" || slot.Session.Capabilities.BrowserName != "chrome" {
+				t.Errorf("slot = %+v, want session s1 on chrome", slot)
+			}
+
+			if !tt.wantStart {
+				if slot.Session.Start != "" {
+					t.Errorf("session start = %q, want none", slot.Session.Start)
+				}
+				return
+			}
+			start, err := time.Parse(time.RFC3339Nano, slot.Session.Start)
+			if err != nil {
+				t.Fatalf("session start %q: %v", slot.Session.Start, err)
+			}
+			if age := time.Since(start); age < 90*time.Minute-5*time.Second || age > 90*time.Minute+5*time.Second {
+				t.Errorf("session age = %v, want 1h30m", age)
+			}
+		})
+	}
+}
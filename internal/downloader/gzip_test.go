@@ -0,0 +1,71 @@
+package downloader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader/downloadertest"
+)
+
+func TestDownloadStatusGzip(t *testing.T) {
+	tests := []struct {
+		name           string
+		inMemory       bool
+		acceptEncoding string // set by the caller, which keeps net/http from decompressing
+	}{
+		{name: "snapshot"},
+		{name: "snapshot with caller Accept-Encoding", acceptEncoding: "gzip"},
+		{name: "in memory", inMemory: true},
+		{name: "in memory with caller Accept-Encoding", inMemory: true, acceptEncoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid := downloadertest.NewFakeGrid(slotsStatus, downloadertest.WithGzip())
+			defer grid.Close()
+			opts := testOptions()
+			opts.InMemory = tt.inMemory
+			opts.DataDir = t.TempDir()
+			if tt.acceptEncoding != "" {
+				opts.Headers = map[string]string{"Accept-Encoding": tt.acceptEncoding}
+			}
+
+			status, err := downloader.DownloadStatus(context.Background(), grid.StatusURL(), opts)
+			if err != nil {
+				t.Fatalf("DownloadStatus() error = %v", err)
+			}
+			if len(status.Value.Nodes) != 1 || status.Value.Nodes[0].Slots[0].Session.SessionID != "s1" {
+				t.Errorf("DownloadStatus() nodes = %+v, want session s1", status.Value.Nodes)
+			}
+
+			if tt.inMemory {
+				return
+			}
+			data, err := os.ReadFile(filepath.Join(opts.DataDir, downloader.SnapshotName(status.FetchedAt, "status.json")))
+			if err != nil {
+				t.Fatalf("reading the snapshot: %v", err)
+			}
+			if string(data) != slotsStatus {
+				t.Errorf("snapshot = %q, want plain JSON", data)
+			}
+		})
+	}
+}
+
+func TestFetchGzip(t *testing.T) {
+	grid := downloadertest.NewFakeGrid(slotsStatus, downloadertest.WithGzip())
+	defer grid.Close()
+	opts := testOptions()
+	opts.Headers = map[string]string{"Accept-Encoding": "gzip"}
+
+	data, err := downloader.Fetch(context.Background(), grid.StatusURL(), opts)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if string(data) != slotsStatus {
+		t.Errorf("Fetch() = %q, want plain JSON", data)
+	}
+}
@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// DefaultCurlImage is the image of the ephemeral pod fetching the status from inside the cluster
+const DefaultCurlImage = "curlimages/curl"
+
+// podCleanupTimeout bounds the deletion of a curl pod left behind by an aborted kubectl run
+const podCleanupTimeout = 30 * time.Second
+
+// FetchViaKubectl fetches the grid status from inside the cluster by running an ephemeral
+// curl pod with `kubectl run` against the path on service:port in the namespace. An empty image uses
+// DefaultCurlImage. kubectl's own output around the document is stripped and the result is
+// checked to be valid JSON. When ctx ends before kubectl does, e.g. on its deadline while
+// the image is being pulled, the pod is deleted and the error wraps ctx.Err().
+func FetchViaKubectl(ctx context.Context, namespace, service string, port int, path, image string) ([]byte, error) {
+	if image == "" {
+		image = DefaultCurlImage
+	}
+	podName := "curl-status-" + strconv.FormatInt(time.Now().UnixNano()%1e9, 36)
+	statusURL := fmt.Sprintf("http://%s:%d%s", service, port, path)
+	args := []string{
+		"run", podName,
+		"-n", namespace,
+		"--image=" + image,
+		"--restart=Never",
+		"--rm", "-i", "--quiet",
+		"--command", "--",
+		"curl", "-s", "--fail", statusURL,
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			// kubectl was killed before it could --rm the pod
+			deleteCurlPod(ctx, namespace, podName)
+			return nil, fmt.Errorf("kubectl run of pod %s aborted: %w", podName, ctx.Err())
+		}
+		return nil, fmt.Errorf("kubectl run failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	data := extractJSON(stdout.Bytes())
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("status from %s is not valid JSON: %q", statusURL, truncate(stdout.Bytes(), 200))
+	}
+	return data, nil
+}
+
+// deleteCurlPod removes a curl pod without waiting for it to terminate. It runs past the
+// end of ctx, which is usually what aborted the pod's kubectl run.
+func deleteCurlPod(ctx context.Context, namespace, podName string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), podCleanupTimeout)
+	defer cancel()
+	_ = exec.CommandContext(ctx, "kubectl", "delete", "pod", podName, "-n", namespace,
+		"--ignore-not-found", "--wait=false").Run()
+}
+
+// extractJSON cuts the outermost JSON object out of the output, dropping kubectl messages
+// such as `pod "curl-status" deleted` printed before or after it
+func extractJSON(output []byte) []byte {
+	start := bytes.IndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if start < 0 || end < start {
+		return output
+	}
+	return output[start : end+1]
+}
+
+func truncate(data []byte, n int) []byte {
+	if len(data) > n {
+		return data[:n]
+	}
+	return data
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pruneStatusFiles removes timestamped status files from dataDir that are older than
+// retainAge or beyond the retainCount most recent ones. Zero values disable the respective
+// limit. The file the latest symlink points at is always kept.
+func pruneStatusFiles(logger *slog.Logger, dataDir string, retainCount int, retainAge time.Duration) error {
+	if retainCount <= 0 && retainAge <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	latest, _ := os.Readlink(filepath.Join(dataDir, statusFile))
+
+	// Timestamped names sort chronologically, newest first after reversing
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && name != statusFile && strings.HasSuffix(name, "-"+statusFile) {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	now := time.Now()
+	for i, name := range names {
+		path := filepath.Join(dataDir, name)
+		if path == latest || filepath.Base(latest) == name {
+			continue
+		}
+
+		expired := retainCount > 0 && i >= retainCount
+		if !expired && retainAge > 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			expired = now.Sub(info.ModTime()) > retainAge
+		}
+		if !expired {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			logger.Warn("Failed to remove old status file", "path", path, "error", err)
+		}
+	}
+	return nil
+}
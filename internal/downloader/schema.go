@@ -0,0 +1,159 @@
+package downloader
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Schema identifies a layout of the grid status document
+type Schema string
+
+const (
+	// SchemaSlots is the Grid 4.8+ layout: sessions live in node slots and each slot
+	// carries its stereotype under "stereotype"
+	SchemaSlots Schema = "grid4-slots"
+	// SchemaSessions is the older Grid 4 layout listing running sessions under node.sessions
+	SchemaSessions Schema = "grid4-sessions"
+	// SchemaGraphQL marks a status built from the Grid 4 GraphQL endpoint instead of /status
+	SchemaGraphQL Schema = "grid4-graphql"
+	// SchemaUnknown is reported when the document matches none of the known layouts
+	SchemaUnknown Schema = "unknown"
+)
+
+// snippetLength bounds how much of an unexpected response is quoted in errors
+const snippetLength = 200
+
+// rawStatus is the part of the status document shared by all schemas
+type rawStatus struct {
+	Value *struct {
+		Message string            `json:"message"`
+		Nodes   []json.RawMessage `json:"nodes"`
+	} `json:"value"`
+}
+
+// legacyNode is a node in the SchemaSessions layout
+type legacyNode struct {
+	ID           string `json:"id"`
+	URI          string `json:"uri"`
+	Availability string `json:"availability"`
+	Sessions     []struct {
+		SessionID           string       `json:"sessionId"`
+		Start               string       `json:"start"`
+		URI                 string       `json:"uri"`
+		Stereotype          Capabilities `json:"stereotype"`
+		Capabilities        Capabilities `json:"capabilities"`
+		CurrentCapabilities Capabilities `json:"currentCapabilities"`
+	} `json:"sessions"`
+}
+
+// detectSchema inspects the nodes of a status document to find its layout. A grid
+// without nodes is reported as SchemaSlots, the two layouts being identical then.
+func detectSchema(raw *rawStatus) Schema {
+	if raw.Value == nil {
+		return SchemaUnknown
+	}
+	for _, rawNode := range raw.Value.Nodes {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(rawNode, &keys); err != nil {
+			return SchemaUnknown
+		}
+		if _, ok := keys["slots"]; ok {
+			return SchemaSlots
+		}
+		if _, ok := keys["sessions"]; ok {
+			return SchemaSessions
+		}
+	}
+	if len(raw.Value.Nodes) == 0 {
+		return SchemaSlots
+	}
+	return SchemaUnknown
+}
+
+// validateShape checks that data is a JSON object with a value.nodes array, so a wrong
+// endpoint, e.g. an HTML error page, fails with an error showing what came back instead
+func validateShape(data []byte) error {
+	var doc struct {
+		Value *struct {
+			Nodes json.RawMessage `json:"nodes"`
+		} `json:"value"`
+	}
+
+	var problem string
+	switch err := json.Unmarshal(data, &doc); {
+	case err != nil:
+		problem = "not a JSON object"
+	case doc.Value == nil:
+		problem = "missing value"
+	case len(doc.Value.Nodes) == 0 || string(doc.Value.Nodes) == "null":
+		problem = "missing value.nodes"
+	case doc.Value.Nodes[0] != '[':
+		problem = "value.nodes is not an array"
+	default:
+		return nil
+	}
+	return fmt.Errorf("response does not look like a Selenium Grid status (%s); got %q",
+		problem, truncate(data, snippetLength))
+}
+
+// parseStatus decodes a status document of any supported schema into Status
+func parseStatus(data []byte) (*Status, error) {
+	if err := validateShape(data); err != nil {
+		return nil, err
+	}
+
+	var raw rawStatus
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse status file: %w", err)
+	}
+
+	schema := detectSchema(&raw)
+	var status Status
+	switch schema {
+	case SchemaSlots:
+		if err := json.Unmarshal(data, &status); err != nil {
+			return nil, fmt.Errorf("failed to parse status file as %s schema: %w", schema, err)
+		}
+	case SchemaSessions:
+		status.Value.Message = raw.Value.Message
+		for _, rawNode := range raw.Value.Nodes {
+			var node legacyNode
+			if err := json.Unmarshal(rawNode, &node); err != nil {
+				return nil, fmt.Errorf("failed to parse status file as %s schema: %w", schema, err)
+			}
+			status.Value.Nodes = append(status.Value.Nodes, node.normalize())
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized status schema: expected nodes with slots (%s) or sessions (%s)",
+			SchemaSlots, SchemaSessions)
+	}
+
+	status.Schema = schema
+	return &status, nil
+}
+
+// normalize converts a legacy node into the slot layout, one slot per running session
+func (n *legacyNode) normalize() Node {
+	node := Node{ID: n.ID, URI: n.URI, Availability: n.Availability}
+	for _, session := range n.Sessions {
+		var slot Slot
+		slot.ID.HostID = n.ID
+		slot.LastStarted = session.Start
+		slot.Stereotype = session.Stereotype
+		slot.Session.SessionID = session.SessionID
+		slot.Session.Start = session.Start
+		slot.Session.URI = session.URI
+		if slot.Session.URI == "" {
+			slot.Session.URI = n.URI
+		}
+		slot.Session.Capabilities = session.Capabilities
+		if slot.Session.Capabilities.BrowserName == "" {
+			slot.Session.Capabilities.BrowserName = session.CurrentCapabilities.BrowserName
+		}
+		if slot.Session.Capabilities.PlatformName == "" {
+			slot.Session.Capabilities.PlatformName = session.CurrentCapabilities.PlatformName
+		}
+		node.Slots = append(node.Slots, slot)
+	}
+	return node
+}
@@ -0,0 +1,206 @@
+// Package gridconn opens the connection to the grid: the Kubernetes client, the
+// port-forward to the grid router and the status download. The selenium-cleaner command
+// and the public grid package build on it.
+package gridconn
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/downloader"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/kubernetes"
+	"github.com/maxkulish/selenium-grid-cleaner/internal/portforwarder"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// DefaultStatusPath is the path of the grid status endpoint when Config.StatusPath is empty
+const DefaultStatusPath = "/wd/hub/status"
+
+// Forwarder forwards a local port to the grid router, e.g. a *portforwarder.PortForwarder
+type Forwarder interface {
+	Start(ctx context.Context) error
+	Stop()
+	GetLocalURL(remoteURL string) string
+}
+
+// Config describes how to reach the grid
+type Config struct {
+	KubeContext string // kubeconfig context, empty for the current one
+	Kubeconfig  string // kubeconfig file, empty for KUBECONFIG or ~/.kube/config
+	Namespace   string // namespace of the grid router
+	Service     string // grid router service
+	Port        int    // grid router port
+	LocalPort   int    // local port of the forward, 0 picks a free one
+	Scheme      string // http or https, http when empty
+	UseKubectl  bool   // forward with kubectl instead of client-go
+
+	Source     string // status or graphql, status when empty
+	StatusPath string // path of the status endpoint, DefaultStatusPath when empty
+	StatusFile string // read the status from this file instead of the grid
+	GridURL    string // base URL of a grid reachable without a port-forward, e.g. http://router:4444
+
+	Download downloader.Options
+
+	// Injected dependencies. Client wins over Clientset; without either a client is built
+	// from the kubeconfig or the in-cluster config.
+	Client     *kubernetes.Client
+	Clientset  k8s.Interface
+	RESTConfig *rest.Config // REST config of Clientset, needed for native port-forwarding
+	HTTPClient *http.Client // client for requests to the grid, built from Download when nil
+	Logger     *slog.Logger // slog.Default() when nil
+
+	// NewForwarder builds the port-forwarder, for settings beyond the ones above.
+	// A plain forwarder to Service is used when nil.
+	NewForwarder func(client *kubernetes.Client) (Forwarder, error)
+}
+
+// Grid is an open connection to the grid: a Kubernetes client and, unless the status is
+// read from a file or GridURL is set, a running port-forward
+type Grid struct {
+	cfg    Config
+	client *kubernetes.Client
+	logger *slog.Logger
+
+	baseURL string // base URL of the grid, empty with StatusFile
+
+	forwarder Forwarder
+	stop      context.CancelFunc
+}
+
+// Open creates the Kubernetes client and starts the port-forward to the grid. Close
+// releases them; cancelling ctx stops the forward as well.
+func Open(ctx context.Context, cfg Config) (*Grid, error) {
+	g := &Grid{cfg: cfg, logger: cfg.Logger}
+	if g.logger == nil {
+		g.logger = slog.Default()
+	}
+	if g.cfg.Scheme == "" {
+		g.cfg.Scheme = "http"
+	}
+	if g.cfg.StatusPath == "" {
+		g.cfg.StatusPath = DefaultStatusPath
+	}
+	if cfg.HTTPClient != nil {
+		g.cfg.Download.HTTPClient = cfg.HTTPClient
+	}
+	// Built once so every fetch reuses the same transport and its connections
+	httpClient, err := downloader.NewHTTPClient(g.cfg.Download)
+	if err != nil {
+		return nil, err
+	}
+	g.cfg.Download.HTTPClient = httpClient
+
+	switch {
+	case cfg.Client != nil:
+		g.client = cfg.Client
+	case cfg.Clientset != nil:
+		g.client = kubernetes.NewClientFromClientset(cfg.Clientset, cfg.RESTConfig, cfg.Namespace)
+	default:
+		client, err := kubernetes.NewClient(cfg.Kubeconfig, cfg.KubeContext, cfg.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		g.client = client
+	}
+
+	switch {
+	case cfg.StatusFile != "":
+		return g, nil
+	case cfg.GridURL != "":
+		g.baseURL = strings.TrimSuffix(cfg.GridURL, "/")
+		return g, nil
+	}
+
+	g.logger.Info("Starting port forwarder...")
+	newForwarder := cfg.NewForwarder
+	if newForwarder == nil {
+		newForwarder = g.newForwarder
+	}
+	pf, err := newForwarder(g.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create port-forwarder: %w", err)
+	}
+
+	forwardCtx, stop := context.WithCancel(ctx)
+	if err := pf.Start(forwardCtx); err != nil {
+		stop()
+		pf.Stop()
+		return nil, fmt.Errorf("failed to start port-forwarding: %w", err)
+	}
+	g.forwarder = pf
+	g.stop = stop
+	g.baseURL = pf.GetLocalURL(fmt.Sprintf("%s://localhost:%d", g.cfg.Scheme, cfg.Port))
+	return g, nil
+}
+
+// newForwarder builds a plain forwarder to the grid service
+func (g *Grid) newForwarder(client *kubernetes.Client) (Forwarder, error) {
+	pf, err := portforwarder.NewPortForwarder(g.cfg.Namespace, g.cfg.Service, g.cfg.Port, g.cfg.LocalPort)
+	if err != nil {
+		return nil, err
+	}
+	if g.cfg.UseKubectl {
+		pf.SetKubeConfig(g.cfg.KubeContext, g.cfg.Kubeconfig)
+	} else {
+		pf.UseNative(client.Config(), client.Clientset())
+	}
+	pf.SetLogger(g.logger)
+	pf.SetReadinessPath(g.cfg.StatusPath)
+	return pf, nil
+}
+
+// Client returns the Kubernetes client
+func (g *Grid) Client() *kubernetes.Client {
+	return g.client
+}
+
+// URL returns the WebDriver base URL of the grid, the status path without its /status
+// suffix, e.g. http://localhost:4444/wd/hub. It is empty when the status is read from a file.
+func (g *Grid) URL() string {
+	if g.baseURL == "" {
+		return ""
+	}
+	return g.baseURL + strings.TrimSuffix(strings.TrimSuffix(g.cfg.StatusPath, "/"), "/status")
+}
+
+// Fetch reads or downloads the current grid status
+func (g *Grid) Fetch(ctx context.Context) (*downloader.Status, error) {
+	if g.cfg.StatusFile != "" {
+		g.logger.Info("Reading Selenium Grid status from file...", "path", g.cfg.StatusFile)
+		status, err := downloader.ParseStatusFile(g.cfg.StatusFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read status file: %w", err)
+		}
+		return status, nil
+	}
+
+	if g.cfg.Source == "graphql" {
+		g.logger.Info("Querying Selenium Grid GraphQL...")
+		status, err := downloader.FetchGraphQL(ctx, g.baseURL+"/graphql", g.cfg.Download)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query grid: %w", err)
+		}
+		return status, nil
+	}
+
+	g.logger.Info("Downloading Selenium Grid status...")
+	status, err := downloader.DownloadStatus(ctx, g.baseURL+g.cfg.StatusPath, g.cfg.Download)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Close stops the port-forward, if any
+func (g *Grid) Close() {
+	if g.forwarder == nil {
+		return
+	}
+	g.logger.Info("Shutting down port forwarder...")
+	g.stop()
+	g.forwarder.Stop()
+}
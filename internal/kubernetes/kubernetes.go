@@ -3,99 +3,554 @@ package kubernetes
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// maxWatchAttempts bounds how often WatchPod restarts after an expired resource version
+const maxWatchAttempts = 3
+
+// ErrPodDeleted is returned by WatchPod when the pod no longer exists
+var ErrPodDeleted = errors.New("pod already deleted")
+
+// ErrPodNotFound is returned when no pod matches a lookup
+var ErrPodNotFound = errors.New("pod not found")
+
+// SessionIDEnv is the container environment variable carrying the Selenium session ID
+const SessionIDEnv = "SE_SESSION_ID"
+
 type Client struct {
-    clientset *kubernetes.Clientset
-    namespace string
+    clientset  kubernetes.Interface
+    config     *rest.Config
+    namespace  string
+    namespaces []string // namespaces searched for pods, metav1.NamespaceAll for every namespace
+    selector   string   // label selector pods must match to be found by IP or session, empty for all
+
+    propagation metav1.DeletionPropagation // propagation policy of pod deletions, foreground when empty
+}
+
+// PodRef identifies a pod by namespace and name
+type PodRef struct {
+    Namespace string
+    Name      string
+}
+
+func (r PodRef) String() string {
+    return r.Namespace + "/" + r.Name
+}
+
+// ClientOption adjusts the REST config before the clientset is created
+type ClientOption func(*rest.Config)
+
+// WithRateLimit overrides the client-side rate limit, which defaults to 5 QPS with
+// bursts of 10. Zero values keep the default.
+func WithRateLimit(qps float32, burst int) ClientOption {
+    return func(config *rest.Config) {
+        if qps > 0 {
+            config.QPS = qps
+        }
+        if burst > 0 {
+            config.Burst = burst
+        }
+    }
+}
+
+// loadConfig returns the REST config for the given kubeconfig file and context. An explicit
+// kubeconfig always wins. Without one the in-cluster config is tried first, then the
+// standard kubectl loading rules: the colon-separated KUBECONFIG list, merged, or
+// ~/.kube/config.
+func loadConfig(kubeconfig, contextName string) (*rest.Config, error) {
+    if kubeconfig == "" {
+        if config, err := rest.InClusterConfig(); err == nil {
+            return config, nil
+        }
+    }
+
+    loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+    loadingRules.ExplicitPath = kubeconfig
+
+    configOverrides := &clientcmd.ConfigOverrides{}
+    if contextName != "" {
+        configOverrides.CurrentContext = contextName
+    }
+
+    kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+        loadingRules,
+        configOverrides)
+
+    config, err := kubeConfig.ClientConfig()
+    if err != nil {
+        return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+    }
+    return config, nil
+}
+
+// NewClient creates a client from the kubeconfig file, see loadConfig, for the namespace
+func NewClient(kubeconfig, contextName string, namespace string, opts ...ClientOption) (*Client, error) {
+    config, err := loadConfig(kubeconfig, contextName)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, opt := range opts {
+        opt(config)
+    }
+
+    clientset, err := kubernetes.NewForConfig(config)
+    if err != nil {
+        return nil, fmt.Errorf("failed to create clientset: %w", err)
+    }
+
+    return &Client{
+        clientset:  clientset,
+        config:     config,
+        namespace:  namespace,
+        namespaces: []string{namespace},
+    }, nil
+}
+
+// SetPodNamespaces sets the namespaces searched for node pods, which default to the client's
+// namespace. An empty list, or metav1.NamespaceAll among them, searches every namespace.
+func (c *Client) SetPodNamespaces(namespaces []string) {
+    if len(namespaces) == 0 || slices.Contains(namespaces, metav1.NamespaceAll) {
+        c.namespaces = []string{metav1.NamespaceAll}
+        return
+    }
+    c.namespaces = namespaces
+}
+
+// PodNamespaces returns the namespaces searched for node pods
+func (c *Client) PodNamespaces() []string {
+    return c.namespaces
+}
+
+// SetPodSelector restricts the pods found by IP or session ID to those matching the label
+// selector, so other pods are never candidates for deletion. An empty selector matches all pods.
+func (c *Client) SetPodSelector(selector string) error {
+    parsed, err := labels.Parse(selector)
+    if err != nil {
+        return fmt.Errorf("invalid pod selector %q: %w", selector, err)
+    }
+    c.selector = parsed.String()
+    return nil
+}
+
+// ParsePropagationPolicy parses a deletion propagation policy name: foreground, background
+// or orphan, in any case
+func ParsePropagationPolicy(name string) (metav1.DeletionPropagation, error) {
+    for _, policy := range []metav1.DeletionPropagation{
+        metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan,
+    } {
+        if strings.EqualFold(name, string(policy)) {
+            return policy, nil
+        }
+    }
+    return "", fmt.Errorf("unknown propagation policy %q (expected foreground, background or orphan)", name)
+}
+
+// SetPropagationPolicy sets the propagation policy of pod deletions. Foreground, the
+// default, keeps the pod until its dependents are deleted; background deletes the pod at
+// once and its dependents afterwards, which is faster for node pods without dependents;
+// orphan leaves dependents in place.
+func (c *Client) SetPropagationPolicy(policy metav1.DeletionPropagation) {
+    c.propagation = policy
+}
+
+// listPods lists the pods matching opts in every searched namespace
+func (c *Client) listPods(ctx context.Context, opts metav1.ListOptions) ([]corev1.Pod, error) {
+    var pods []corev1.Pod
+    for _, namespace := range c.namespaces {
+        list, err := c.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+        if err != nil {
+            return nil, err
+        }
+        pods = append(pods, list.Items...)
+    }
+    return pods, nil
+}
+
+// CanonicalIP returns ip in the canonical form the API server reports pod IPs in, which
+// matters for IPv6 addresses with several spellings. Anything but an IP is returned as is.
+func CanonicalIP(ip string) string {
+    if parsed := net.ParseIP(ip); parsed != nil {
+        return parsed.String()
+    }
+    return ip
+}
+
+// podRefs returns references to the given pods
+func podRefs(pods []corev1.Pod) []PodRef {
+    var refs []PodRef
+    for _, pod := range pods {
+        refs = append(refs, PodRef{Namespace: pod.Namespace, Name: pod.Name})
+    }
+    return refs
+}
+
+// InCluster reports whether the process runs inside a Kubernetes pod with a service account
+func InCluster() bool {
+    _, err := rest.InClusterConfig()
+    return err == nil
 }
 
-func NewClient(contextName string, namespace string) (*Client, error) {
-    // Try in-cluster config first
-    config, err := rest.InClusterConfig()
+// GetServiceClusterIP returns the cluster IP of a service
+func (c *Client) GetServiceClusterIP(ctx context.Context, namespace, service string) (string, error) {
+    svc, err := c.clientset.CoreV1().Services(namespace).Get(ctx, service, metav1.GetOptions{})
     if err != nil {
-        // If in-cluster fails, try kubeconfig
-        kubeconfig := os.Getenv("KUBECONFIG")
-        if kubeconfig == "" {
-            homeDir, err := os.UserHomeDir()
-            if err != nil {
-                return nil, fmt.Errorf("failed to get user home directory: %w", err)
+        return "", fmt.Errorf("failed to get service %s: %w", service, err)
+    }
+    if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+        return "", fmt.Errorf("service %s has no cluster IP", service)
+    }
+    return svc.Spec.ClusterIP, nil
+}
+
+// Config returns the REST config the client was built from
+func (c *Client) Config() *rest.Config {
+    return c.config
+}
+
+// Clientset returns the underlying Kubernetes clientset
+func (c *Client) Clientset() kubernetes.Interface {
+    return c.clientset
+}
+
+// NewClientFromClientset wraps an existing clientset, e.g. a fake one in tests. config is
+// only needed for native port-forwarding and may be nil.
+func NewClientFromClientset(clientset kubernetes.Interface, config *rest.Config, namespace string) *Client {
+    return &Client{
+        clientset:  clientset,
+        config:     config,
+        namespace:  namespace,
+        namespaces: []string{namespace},
+    }
+}
+
+// GetPodsByIP returns the pods in the searched namespaces that match the given IP address.
+// The lookup uses a status.podIP field selector; API servers that reject it fall back to a
+// client-side scan of the namespaces.
+func (c *Client) GetPodsByIP(ctx context.Context, podIP string) ([]PodRef, error) {
+    podIP = CanonicalIP(podIP)
+    pods, err := c.listPods(ctx, metav1.ListOptions{
+        FieldSelector: fields.OneTermEqualSelector("status.podIP", podIP).String(),
+        LabelSelector: c.selector,
+    })
+    if apierrors.IsBadRequest(err) {
+        return c.scanPodsByIP(ctx, podIP)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to list pods: %w", err)
+    }
+
+    return podRefs(pods), nil
+}
+
+// scanPodsByIP lists every pod in the searched namespaces and filters them by IP on the client
+func (c *Client) scanPodsByIP(ctx context.Context, podIP string) ([]PodRef, error) {
+    pods, err := c.listPods(ctx, metav1.ListOptions{LabelSelector: c.selector})
+    if err != nil {
+        return nil, fmt.Errorf("failed to list pods: %w", err)
+    }
+
+    var refs []PodRef
+    for _, pod := range pods {
+        if CanonicalIP(pod.Status.PodIP) == podIP {
+            refs = append(refs, PodRef{Namespace: pod.Namespace, Name: pod.Name})
+        }
+    }
+
+    return refs, nil
+}
+
+// podHasSessionID reports whether any container of the pod has SE_SESSION_ID set to sessionID
+func podHasSessionID(pod *corev1.Pod, sessionID string) bool {
+    for _, container := range pod.Spec.Containers {
+        for _, env := range container.Env {
+            if env.Name == SessionIDEnv && env.Value == sessionID {
+                return true
             }
-            kubeconfig = filepath.Join(homeDir, ".kube", "config")
         }
+    }
+    return false
+}
+
+// GetPodNameBySessionID returns the pod in the searched namespaces, among those matching the
+// pod selector, whose containers carry the given session ID
+func (c *Client) GetPodNameBySessionID(ctx context.Context, sessionID string) (PodRef, error) {
+    pods, err := c.listPods(ctx, metav1.ListOptions{LabelSelector: c.selector})
+    if err != nil {
+        return PodRef{}, fmt.Errorf("failed to list pods: %w", err)
+    }
+
+    for i := range pods {
+        if podHasSessionID(&pods[i], sessionID) {
+            return PodRef{Namespace: pods[i].Namespace, Name: pods[i].Name}, nil
+        }
+    }
+
+    return PodRef{}, fmt.Errorf("%w for session %s", ErrPodNotFound, sessionID)
+}
+
+// GetPodNameBySessionIDLabeled returns the name of the pod labelled labelKey=sessionID in the
+// namespace, among those matching the pod selector. Only matching pods are listed; when none
+// carries the label the namespace is scanned for the session ID in the container environment
+// instead.
+func (c *Client) GetPodNameBySessionIDLabeled(ctx context.Context, namespace, labelKey, sessionID string) (string, error) {
+    selector, err := labels.Parse(c.selector)
+    if err != nil {
+        return "", fmt.Errorf("invalid pod selector %q: %w", c.selector, err)
+    }
+    requirement, err := labels.NewRequirement(labelKey, selection.Equals, []string{sessionID})
+    if err != nil {
+        return c.scanPodsBySessionID(ctx, namespace, sessionID)
+    }
+
+    pods, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+        LabelSelector: selector.Add(*requirement).String(),
+    })
+    if err != nil {
+        return "", fmt.Errorf("failed to list pods: %w", err)
+    }
+    if len(pods.Items) > 0 {
+        return pods.Items[0].Name, nil
+    }
 
-        loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-        loadingRules.ExplicitPath = kubeconfig
+    return c.scanPodsBySessionID(ctx, namespace, sessionID)
+}
+
+// scanPodsBySessionID lists the pods matching the pod selector and returns the one in the
+// namespace whose containers carry the session ID
+func (c *Client) scanPodsBySessionID(ctx context.Context, namespace, sessionID string) (string, error) {
+    pods, err := c.listPods(ctx, metav1.ListOptions{LabelSelector: c.selector})
+    if err != nil {
+        return "", fmt.Errorf("failed to list pods: %w", err)
+    }
 
-        configOverrides := &clientcmd.ConfigOverrides{}
-        if contextName != "" {
-            configOverrides.CurrentContext = contextName
+    for i := range pods {
+        if pods[i].Namespace == namespace && podHasSessionID(&pods[i], sessionID) {
+            return pods[i].Name, nil
         }
+    }
 
-        kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-            loadingRules,
-            configOverrides)
+    return "", fmt.Errorf("%w for session %s", ErrPodNotFound, sessionID)
+}
 
-        config, err = kubeConfig.ClientConfig()
+// CheckAccess performs a minimal pod list to verify the API server is reachable and the
+// credentials are allowed to read pods in the searched namespaces
+func (c *Client) CheckAccess(ctx context.Context) error {
+    for _, namespace := range c.namespaces {
+        _, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{Limit: 1})
         if err != nil {
-            return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+            if namespace == metav1.NamespaceAll {
+                return fmt.Errorf("failed to list pods in all namespaces: %w", err)
+            }
+            return fmt.Errorf("failed to list pods in namespace %s: %w", namespace, err)
         }
     }
+    return nil
+}
 
-    clientset, err := kubernetes.NewForConfig(config)
+// ListPods returns the pods in the searched namespaces that match the pod selector, the
+// candidates of all lookups by IP or session
+func (c *Client) ListPods(ctx context.Context) ([]corev1.Pod, error) {
+    pods, err := c.listPods(ctx, metav1.ListOptions{LabelSelector: c.selector})
     if err != nil {
-        return nil, fmt.Errorf("failed to create clientset: %w", err)
+        return nil, fmt.Errorf("failed to list pods: %w", err)
     }
 
-    return &Client{
-        clientset: clientset,
-        namespace: namespace,
-    }, nil
+    return pods, nil
 }
 
-// GetPodsByIP returns pod names that match the given IP address
-func (c *Client) GetPodsByIP(ctx context.Context, podIP string) ([]string, error) {
-    pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{
-        FieldSelector: fmt.Sprintf("status.podIP=%s", podIP),
+// ListNodePods returns the pods in the searched namespaces matching the given label selector
+func (c *Client) ListNodePods(ctx context.Context, labelSelector string) ([]corev1.Pod, error) {
+    pods, err := c.listPods(ctx, metav1.ListOptions{
+        LabelSelector: labelSelector,
     })
     if err != nil {
         return nil, fmt.Errorf("failed to list pods: %w", err)
     }
 
-    var podNames []string
-    for _, pod := range pods.Items {
-        podNames = append(podNames, pod.Name)
+    return pods, nil
+}
+
+// GetPod returns the full pod object by namespace and name, for callers that need its
+// labels, annotations, creation timestamp, node or status
+func (c *Client) GetPod(ctx context.Context, namespace, podName string) (*corev1.Pod, error) {
+    pod, err := c.clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+    if err != nil {
+        return nil, fmt.Errorf("failed to get pod: %w", err)
+    }
+
+    return pod, nil
+}
+
+// GetPodAnnotations returns the annotations of a pod by namespace and name
+func (c *Client) GetPodAnnotations(ctx context.Context, namespace, podName string) (map[string]string, error) {
+    pod, err := c.GetPod(ctx, namespace, podName)
+    if err != nil {
+        return nil, err
+    }
+
+    return pod.Annotations, nil
+}
+
+// GetPodNodeName returns the name of the Kubernetes node a pod is scheduled on, empty if
+// it is not scheduled yet
+func (c *Client) GetPodNodeName(ctx context.Context, namespace, podName string) (string, error) {
+    pod, err := c.GetPod(ctx, namespace, podName)
+    if err != nil {
+        return "", err
+    }
+
+    return pod.Spec.NodeName, nil
+}
+
+// CordonNode marks a node unschedulable. It reports whether this call cordoned the node,
+// false meaning it was already cordoned.
+func (c *Client) CordonNode(ctx context.Context, nodeName string) (bool, error) {
+    node, err := c.clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+    if err != nil {
+        return false, fmt.Errorf("failed to get node: %w", err)
+    }
+    if node.Spec.Unschedulable {
+        return false, nil
+    }
+
+    if err := c.setNodeUnschedulable(ctx, nodeName, true); err != nil {
+        return false, fmt.Errorf("failed to cordon node: %w", err)
+    }
+    return true, nil
+}
+
+// UncordonNode marks a node schedulable again
+func (c *Client) UncordonNode(ctx context.Context, nodeName string) error {
+    if err := c.setNodeUnschedulable(ctx, nodeName, false); err != nil {
+        return fmt.Errorf("failed to uncordon node: %w", err)
+    }
+    return nil
+}
+
+func (c *Client) setNodeUnschedulable(ctx context.Context, nodeName string, unschedulable bool) error {
+    patch := []byte(fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable))
+    _, err := c.clientset.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+    return err
+}
+
+// EventComponent is the source component of the events recorded by the cleaner
+const EventComponent = "selenium-grid-cleaner"
+
+// RecordPodEvent records a Normal event involving the pod, shown by kubectl get events
+func (c *Client) RecordPodEvent(ctx context.Context, namespace, podName, reason, message string) error {
+    now := metav1.Now()
+    event := &corev1.Event{
+        ObjectMeta: metav1.ObjectMeta{
+            GenerateName: podName + ".",
+            Namespace:    namespace,
+        },
+        InvolvedObject: corev1.ObjectReference{
+            Kind:       "Pod",
+            APIVersion: "v1",
+            Namespace:  namespace,
+            Name:       podName,
+        },
+        Reason:              reason,
+        Message:             message,
+        Type:                corev1.EventTypeNormal,
+        Source:              corev1.EventSource{Component: EventComponent},
+        ReportingController: EventComponent,
+        FirstTimestamp:      now,
+        LastTimestamp:       now,
+        Count:               1,
+    }
+    if _, err := c.clientset.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+        return fmt.Errorf("failed to create event: %w", err)
     }
+    return nil
+}
 
-    return podNames, nil
+// Namespace returns the primary namespace of the client, the one the grid router runs in
+func (c *Client) Namespace() string {
+    return c.namespace
 }
 
-// DeletePod deletes a pod by name
-func (c *Client) DeletePod(ctx context.Context, podName string) error {
-    deletePolicy := metav1.DeletePropagationForeground
+// DeletePod deletes a pod by name in the given namespace with the propagation policy of
+// SetPropagationPolicy. A non-nil gracePeriod overrides the pod's
+// terminationGracePeriodSeconds, 0 deleting it immediately.
+func (c *Client) DeletePod(ctx context.Context, namespace, podName string, gracePeriod *int64) error {
+    deletePolicy := c.propagation
+    if deletePolicy == "" {
+        deletePolicy = metav1.DeletePropagationForeground
+    }
     deleteOptions := metav1.DeleteOptions{
-        PropagationPolicy: &deletePolicy,
+        PropagationPolicy:  &deletePolicy,
+        GracePeriodSeconds: gracePeriod,
     }
 
-    if err := c.clientset.CoreV1().Pods(c.namespace).Delete(ctx, podName, deleteOptions); err != nil {
+    if err := c.clientset.CoreV1().Pods(namespace).Delete(ctx, podName, deleteOptions); err != nil {
         return fmt.Errorf("failed to delete pod: %w", err)
     }
 
     return nil
 }
 
-// WatchPod creates a watcher for a specific pod
-func (c *Client) WatchPod(ctx context.Context, podName string) (watch.Interface, error) {
-    return c.clientset.CoreV1().Pods(c.namespace).Watch(ctx, metav1.ListOptions{
-        FieldSelector: fmt.Sprintf("metadata.name=%s", podName),
-    })
+// RemovePodFinalizers clears the finalizers of a pod so a pending deletion can complete
+func (c *Client) RemovePodFinalizers(ctx context.Context, namespace, podName string) error {
+    patch := []byte(`{"metadata":{"finalizers":null}}`)
+    if _, err := c.clientset.CoreV1().Pods(namespace).Patch(ctx, podName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+        return fmt.Errorf("failed to remove finalizers: %w", err)
+    }
+    return nil
+}
+
+// WatchPod creates a watcher for a specific pod. The pod is fetched first so the watch starts
+// from its current resource version and cannot miss a deletion that happens in between; if the
+// pod is already gone ErrPodDeleted is returned. Expired resource versions (410 Gone) are
+// handled by fetching the pod again.
+func (c *Client) WatchPod(ctx context.Context, namespace, podName string) (watch.Interface, error) {
+    pods := c.clientset.CoreV1().Pods(namespace)
+
+    for attempt := 1; ; attempt++ {
+        pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+        if apierrors.IsNotFound(err) {
+            return nil, ErrPodDeleted
+        }
+        if err != nil {
+            return nil, fmt.Errorf("failed to get pod: %w", err)
+        }
+
+        watcher, err := pods.Watch(ctx, metav1.ListOptions{
+            FieldSelector:   fields.OneTermEqualSelector("metadata.name", podName).String(),
+            ResourceVersion: pod.ResourceVersion,
+        })
+        if (apierrors.IsResourceExpired(err) || apierrors.IsGone(err)) && attempt < maxWatchAttempts {
+            continue
+        }
+        if err != nil {
+            return nil, fmt.Errorf("failed to watch pod: %w", err)
+        }
+
+        return watcher, nil
+    }
+}
+
+// IsWatchExpired reports whether a watch error event signals an expired resource version (410 Gone)
+func IsWatchExpired(event watch.Event) bool {
+    status, ok := event.Object.(*metav1.Status)
+    return ok && status.Code == http.StatusGone
 }
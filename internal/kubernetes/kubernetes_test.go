@@ -0,0 +1,343 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "selenium"
+
+// testPod returns a pod in the test namespace with the given IP and labels
+func testPod(name, podIP string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, Labels: podLabels},
+		Status:     corev1.PodStatus{PodIP: podIP},
+	}
+}
+
+// newFieldSelectingClientset returns a fake clientset whose pod lists honour the
+// status.podIP field selector like the API server does, which the fake tracker does not.
+// fieldSelectors counts the lists that carried a field selector.
+func newFieldSelectingClientset(objects ...runtime.Object) (clientset *fake.Clientset, fieldSelectors *int) {
+	clientset = fake.NewClientset(objects...)
+	fieldSelectors = new(int)
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		restrictions := action.(k8stesting.ListAction).GetListRestrictions()
+		if restrictions.Fields == nil || restrictions.Fields.Empty() {
+			return false, nil, nil
+		}
+		*fieldSelectors++
+
+		obj, err := clientset.Tracker().List(corev1.SchemeGroupVersion.WithResource("pods"),
+			corev1.SchemeGroupVersion.WithKind("Pod"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		list := &corev1.PodList{}
+		for _, pod := range obj.(*corev1.PodList).Items {
+			if restrictions.Labels.Matches(labels.Set(pod.Labels)) &&
+				restrictions.Fields.Matches(fields.Set{"status.podIP": pod.Status.PodIP}) {
+				list.Items = append(list.Items, pod)
+			}
+		}
+		return true, list, nil
+	})
+	return clientset, fieldSelectors
+}
+
+func podNames(refs []PodRef) []string {
+	var names []string
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestGetPodsByIP(t *testing.T) {
+	pods := []runtime.Object{
+		testPod("node-a", "10.0.0.1", map[string]string{"purpose": "ci"}),
+		testPod("node-b", "10.0.0.2", map[string]string{"purpose": "ci"}),
+		testPod("node-b-old", "10.0.0.2", map[string]string{"purpose": "manual"}),
+		testPod("node-v6", "fd00::1", nil),
+	}
+
+	tests := []struct {
+		name       string
+		ip         string
+		selector   string
+		badRequest bool // the API server rejects the status.podIP field selector
+		want       []string
+	}{
+		{name: "no match", ip: "10.0.0.9", want: nil},
+		{name: "one match", ip: "10.0.0.1", want: []string{"node-a"}},
+		{name: "several matches", ip: "10.0.0.2", want: []string{"node-b", "node-b-old"}},
+		{name: "pod selector", ip: "10.0.0.2", selector: "purpose=ci", want: []string{"node-b"}},
+		{name: "non-canonical IPv6", ip: "FD00:0::0001", want: []string{"node-v6"}},
+		{name: "fallback no match", ip: "10.0.0.9", badRequest: true, want: nil},
+		{name: "fallback one match", ip: "10.0.0.1", badRequest: true, want: []string{"node-a"}},
+		{name: "fallback several matches", ip: "10.0.0.2", badRequest: true, want: []string{"node-b", "node-b-old"}},
+		{name: "fallback pod selector", ip: "10.0.0.2", selector: "purpose=manual", badRequest: true, want: []string{"node-b-old"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset, fieldSelectors := newFieldSelectingClientset(pods...)
+			if tt.badRequest {
+				clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					if restrictions := action.(k8stesting.ListAction).GetListRestrictions(); restrictions.Fields != nil && !restrictions.Fields.Empty() {
+						return true, nil, apierrors.NewBadRequest(`field label not supported: status.podIP`)
+					}
+					return false, nil, nil
+				})
+			}
+			client := NewClientFromClientset(clientset, nil, testNamespace)
+			if err := client.SetPodSelector(tt.selector); err != nil {
+				t.Fatalf("SetPodSelector() error = %v", err)
+			}
+
+			refs, err := client.GetPodsByIP(context.Background(), tt.ip)
+			if err != nil {
+				t.Fatalf("GetPodsByIP() error = %v", err)
+			}
+			if got := podNames(refs); !slices.Equal(got, tt.want) {
+				t.Errorf("GetPodsByIP() = %v, want %v", got, tt.want)
+			}
+			if !tt.badRequest && *fieldSelectors != 1 {
+				t.Errorf("lists with a field selector = %d, want 1", *fieldSelectors)
+			}
+		})
+	}
+}
+
+func TestGetPodNameBySessionIDLabeledHonoursSelector(t *testing.T) {
+	withSessionEnv := func(pod *corev1.Pod, sessionID string) *corev1.Pod {
+		pod.Spec.Containers = []corev1.Container{{
+			Name: "node",
+			Env:  []corev1.EnvVar{{Name: SessionIDEnv, Value: sessionID}},
+		}}
+		return pod
+	}
+	pods := []runtime.Object{
+		testPod("node-manual", "10.0.0.1", map[string]string{"purpose": "manual", "session": "s1"}),
+		testPod("node-ci", "10.0.0.2", map[string]string{"purpose": "ci", "session": "s2"}),
+		withSessionEnv(testPod("node-manual-env", "10.0.0.3", map[string]string{"purpose": "manual"}), "s3"),
+		withSessionEnv(testPod("node-ci-env", "10.0.0.4", map[string]string{"purpose": "ci"}), "s4"),
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		want      string
+		wantErr   error
+	}{
+		{name: "label inside the selector", sessionID: "s2", want: "node-ci"},
+		{name: "label outside the selector", sessionID: "s1", wantErr: ErrPodNotFound},
+		{name: "environment inside the selector", sessionID: "s4", want: "node-ci-env"},
+		{name: "environment outside the selector", sessionID: "s3", wantErr: ErrPodNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClientFromClientset(fake.NewClientset(pods...), nil, testNamespace)
+			if err := client.SetPodSelector("purpose=ci"); err != nil {
+				t.Fatalf("SetPodSelector() error = %v", err)
+			}
+
+			got, err := client.GetPodNameBySessionIDLabeled(context.Background(), testNamespace, "session", tt.sessionID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetPodNameBySessionIDLabeled() = %q, %v, want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetPodNameBySessionIDLabeled() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetPodNameBySessionIDLabeled() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPod(t *testing.T) {
+	created := metav1.NewTime(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	pod := testPod("node-a", "10.0.0.1", map[string]string{"app": "selenium-node"})
+	pod.Annotations = map[string]string{"selenium-cleaner/protect": "true"}
+	pod.CreationTimestamp = created
+	pod.Spec.NodeName = "worker-1"
+	client := NewClientFromClientset(fake.NewClientset(pod), nil, testNamespace)
+
+	got, err := client.GetPod(context.Background(), testNamespace, "node-a")
+	if err != nil {
+		t.Fatalf("GetPod() error = %v", err)
+	}
+	if got.Labels["app"] != "selenium-node" || got.Annotations["selenium-cleaner/protect"] != "true" {
+		t.Errorf("GetPod() labels = %v, annotations = %v", got.Labels, got.Annotations)
+	}
+	if !got.CreationTimestamp.Equal(&created) || got.Spec.NodeName != "worker-1" || got.Status.PodIP != "10.0.0.1" {
+		t.Errorf("GetPod() created = %v, node = %q, IP = %q", got.CreationTimestamp, got.Spec.NodeName, got.Status.PodIP)
+	}
+
+	annotations, err := client.GetPodAnnotations(context.Background(), testNamespace, "node-a")
+	if err != nil || annotations["selenium-cleaner/protect"] != "true" {
+		t.Errorf("GetPodAnnotations() = %v, %v", annotations, err)
+	}
+	nodeName, err := client.GetPodNodeName(context.Background(), testNamespace, "node-a")
+	if err != nil || nodeName != "worker-1" {
+		t.Errorf("GetPodNodeName() = %q, %v", nodeName, err)
+	}
+}
+
+func TestGetPodNotFound(t *testing.T) {
+	client := NewClientFromClientset(fake.NewClientset(testPod("node-a", "10.0.0.1", nil)), nil, testNamespace)
+
+	for _, ref := range []PodRef{{Namespace: testNamespace, Name: "node-b"}, {Namespace: "other", Name: "node-a"}} {
+		_, err := client.GetPod(context.Background(), ref.Namespace, ref.Name)
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("GetPod(%s) error = %v, want a NotFound error", ref, err)
+		}
+	}
+}
+
+func TestWatchPodAlreadyDeleted(t *testing.T) {
+	client := NewClientFromClientset(fake.NewClientset(), nil, testNamespace)
+
+	_, err := client.WatchPod(context.Background(), testNamespace, "node-a")
+	if !errors.Is(err, ErrPodDeleted) {
+		t.Fatalf("WatchPod() error = %v, want ErrPodDeleted", err)
+	}
+}
+
+func TestWatchPodDeletedBetweenGetAndWatch(t *testing.T) {
+	pod := testPod("node-a", "10.0.0.1", nil)
+	pod.ResourceVersion = "42"
+	clientset := fake.NewClientset(pod)
+
+	// The pod vanishes right after it was fetched, before the watch starts
+	podsResource := corev1.SchemeGroupVersion.WithResource("pods")
+	clientset.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := clientset.Tracker().Get(podsResource, testNamespace, "node-a")
+		if err != nil {
+			return true, nil, err
+		}
+		if err := clientset.Tracker().Delete(podsResource, testNamespace, "node-a"); err != nil {
+			return true, nil, err
+		}
+		return true, obj, nil
+	})
+
+	// Watching from the fetched resource version, the API server replays the deletion
+	var restrictions k8stesting.WatchRestrictions
+	clientset.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		restrictions = action.(k8stesting.WatchAction).GetWatchRestrictions()
+		watcher := watch.NewFakeWithChanSize(1, false)
+		if restrictions.ResourceVersion == pod.ResourceVersion {
+			watcher.Delete(pod)
+		}
+		return true, watcher, nil
+	})
+
+	client := NewClientFromClientset(clientset, nil, testNamespace)
+	watcher, err := client.WatchPod(context.Background(), testNamespace, "node-a")
+	if err != nil {
+		t.Fatalf("WatchPod() error = %v", err)
+	}
+	defer watcher.Stop()
+
+	if restrictions.ResourceVersion != "42" {
+		t.Errorf("watch resource version = %q, want 42", restrictions.ResourceVersion)
+	}
+	if !restrictions.Fields.Matches(fields.Set{"metadata.name": "node-a"}) ||
+		restrictions.Fields.Matches(fields.Set{"metadata.name": "node-b"}) {
+		t.Errorf("watch field selector = %q, want metadata.name=node-a", restrictions.Fields)
+	}
+
+	select {
+	case event := <-watcher.ResultChan():
+		if event.Type != watch.Deleted {
+			t.Errorf("first watch event = %s, want %s", event.Type, watch.Deleted)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no watch event for the pod deleted between Get and Watch")
+	}
+}
+
+func TestWatchPodRetriesExpiredResourceVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		expired  int // watches failing with 410 Gone before one succeeds
+		wantErr  bool
+		wantGets int
+	}{
+		{name: "expired once", expired: 1, wantGets: 2},
+		{name: "expired until the last attempt", expired: maxWatchAttempts - 1, wantGets: maxWatchAttempts},
+		{name: "expired on every attempt", expired: maxWatchAttempts, wantErr: true, wantGets: maxWatchAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientset(testPod("node-a", "10.0.0.1", nil))
+
+			gets := 0
+			clientset.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+				gets++
+				return false, nil, nil
+			})
+			watches := 0
+			clientset.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+				watches++
+				if watches <= tt.expired {
+					return true, nil, apierrors.NewResourceExpired("too old resource version")
+				}
+				return true, watch.NewFake(), nil
+			})
+
+			client := NewClientFromClientset(clientset, nil, testNamespace)
+			watcher, err := client.WatchPod(context.Background(), testNamespace, "node-a")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WatchPod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if watcher != nil {
+				watcher.Stop()
+			}
+			if gets != tt.wantGets {
+				t.Errorf("pod gets = %d, want %d", gets, tt.wantGets)
+			}
+		})
+	}
+}
+
+func TestCanonicalIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "10.0.0.1", want: "10.0.0.1"},
+		{ip: "fd00::1", want: "fd00::1"},
+		{ip: "FD00:0:0::0001", want: "fd00::1"},
+		{ip: "::ffff:10.0.0.1", want: "10.0.0.1"},
+		{ip: "selenium-node.selenium.svc", want: "selenium-node.selenium.svc"},
+		{ip: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := CanonicalIP(tt.ip); got != tt.want {
+			t.Errorf("CanonicalIP(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
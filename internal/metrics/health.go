@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+)
+
+// HealthHandler answers with 200 while the last cleanup run finished less than maxAge ago
+// and with 503 once it is older, e.g. because the port-forward is down and every status
+// download fails. Until the first run finishes, started counts as the last run.
+func HealthHandler(stats *cleaner.CleanupStats, started time.Time, maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastRun, event := stats.Snapshot().LastRun, "last cleanup run finished"
+		if lastRun.IsZero() {
+			lastRun, event = started, "no cleanup run finished yet, started"
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		age := time.Since(lastRun).Round(time.Second)
+		if age > maxAge {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "stale: %s %v ago, more than %v\n", event, age, maxAge)
+			return
+		}
+		fmt.Fprintf(w, "ok: %s %v ago\n", event, age)
+	})
+}
+
+// ServeHealth exposes HealthHandler on addr under /healthz until ctx is cancelled
+func ServeHealth(ctx context.Context, addr string, stats *cleaner.CleanupStats, maxAge time.Duration) error {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", HealthHandler(stats, time.Now(), maxAge))
+
+	slog.Info("Serving health checks", "addr", addr, "path", "/healthz", "max_age", maxAge.String())
+	return serve(ctx, "health", addr, mux)
+}
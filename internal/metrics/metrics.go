@@ -0,0 +1,73 @@
+// Package metrics exposes the cleanup counters in the Prometheus text format
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+)
+
+// shutdownTimeout bounds how long in-flight scrapes may take once the server is stopped
+const shutdownTimeout = 5 * time.Second
+
+// Handler serves the current cleanup counters of stats in the Prometheus text format
+func Handler(stats *cleaner.CleanupStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, stats.Snapshot())
+	})
+}
+
+// writeMetrics renders a snapshot in the Prometheus text exposition format
+func writeMetrics(w io.Writer, snap cleaner.StatsSnapshot) {
+	write := func(name, kind, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+	}
+
+	write("selenium_cleaner_runs_total", "counter", "Cleanup runs completed.", float64(snap.Runs))
+	write("selenium_cleaner_sessions_total", "counter", "Sessions found in the grid status.", float64(snap.SessionsSeen))
+	write("selenium_cleaner_sessions_expired_total", "counter", "Sessions that exceeded their max age.", float64(snap.SessionsExpired))
+	write("selenium_cleaner_pods_deleted_total", "counter", "Pods deleted.", float64(snap.PodsDeleted))
+	write("selenium_cleaner_deletion_errors_total", "counter", "Sessions whose cleanup failed.", float64(snap.DeletionFailures))
+	write("selenium_cleaner_cleanup_seconds_total", "counter", "Total time spent cleaning up.", snap.CleanupDuration.Seconds())
+
+	var lastRun float64
+	if !snap.LastRun.IsZero() {
+		lastRun = float64(snap.LastRun.Unix())
+	}
+	write("selenium_cleaner_last_run_timestamp", "gauge", "Unix time the last cleanup run finished.", lastRun)
+}
+
+// Serve exposes the metrics on addr under /metrics until ctx is cancelled
+func Serve(ctx context.Context, addr string, stats *cleaner.CleanupStats) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(stats))
+
+	slog.Info("Serving metrics", "addr", addr, "path", "/metrics")
+	return serve(ctx, "metrics", addr, mux)
+}
+
+// serve runs an HTTP server for handler on addr until ctx is cancelled
+func serve(ctx context.Context, name, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Server shutdown failed", "server", name, "error", err)
+		}
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s server: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+)
+
+// ServePprof exposes the runtime profiles of net/http/pprof on addr under /debug/pprof/
+// until ctx is cancelled. The profiles are served from a dedicated mux; net/http/pprof also
+// registers them on http.DefaultServeMux, which the cleaner never serves.
+func ServePprof(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	slog.Info("Serving pprof", "addr", addr, "path", "/debug/pprof/")
+	return serve(ctx, "pprof", addr, mux)
+}
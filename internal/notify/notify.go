@@ -0,0 +1,136 @@
+// Package notify posts a summary of each cleanup run to a webhook
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/maxkulish/selenium-grid-cleaner/internal/cleaner"
+)
+
+// Summary is the outcome of a cleanup run as sent to the webhook
+type Summary struct {
+	DeletedPods []string          `json:"deletedPods"`
+	Skipped     int               `json:"skipped"`
+	Errors      map[string]string `json:"errors"`   // keyed by session ID
+	TimedOut    []string          `json:"timedOut"` // session IDs in Errors that hit the session timeout
+	StartTime   time.Time         `json:"startTime"`
+	Duration    string            `json:"duration"`
+	DryRun      bool              `json:"dryRun"`
+	Refused     string            `json:"refused,omitempty"` // why the safety valve stopped the run
+}
+
+// NewSummary builds the summary of a cleanup result
+func NewSummary(result *cleaner.CleanupResult, dryRun bool) Summary {
+	summary := Summary{
+		DeletedPods: append([]string{}, result.Deleted...),
+		Skipped:     len(result.Skipped),
+		Errors:      make(map[string]string, len(result.Failed)),
+		TimedOut:    append([]string{}, result.TimedOut...),
+		StartTime:   result.StartTime,
+		Duration:    result.Duration.Round(time.Millisecond).String(),
+		DryRun:      dryRun,
+	}
+	for sessionID, err := range result.Failed {
+		summary.Errors[sessionID] = err.Error()
+	}
+	if result.Refused != nil {
+		summary.Refused = result.Refused.Error()
+	}
+	return summary
+}
+
+// Formatter encodes a summary into a webhook request body
+type Formatter func(Summary) ([]byte, error)
+
+// Formats are the supported payload formats by name
+var Formats = map[string]Formatter{
+	"json":  FormatJSON,
+	"slack": FormatSlack,
+}
+
+// FormatJSON sends the summary as is
+func FormatJSON(s Summary) ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FormatSlack renders the summary as the text of a Slack incoming webhook message
+func FormatSlack(s Summary) ([]byte, error) {
+	var text strings.Builder
+	prefix := ""
+	if s.DryRun {
+		prefix = "[dry run] "
+	}
+	fmt.Fprintf(&text, "%sSelenium Grid Cleaner: deleted %d pods, skipped %d sessions, %d errors in %s",
+		prefix, len(s.DeletedPods), s.Skipped, len(s.Errors), s.Duration)
+	if s.Refused != "" {
+		fmt.Fprintf(&text, "\n• refused: %s", s.Refused)
+	}
+	for _, pod := range s.DeletedPods {
+		fmt.Fprintf(&text, "\n• deleted `%s`", pod)
+	}
+
+	sessionIDs := make([]string, 0, len(s.Errors))
+	for sessionID := range s.Errors {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	sort.Strings(sessionIDs)
+	for _, sessionID := range sessionIDs {
+		fmt.Fprintf(&text, "\n• failed `%s`: %s", sessionID, s.Errors[sessionID])
+	}
+
+	return json.Marshal(map[string]string{"text": text.String()})
+}
+
+// Notifier posts run summaries to a webhook. The zero value, or one with an empty URL,
+// does nothing.
+type Notifier struct {
+	URL     string
+	Format  Formatter     // defaults to FormatJSON
+	Timeout time.Duration // 0 means no timeout
+}
+
+// Notify posts the summary to the webhook
+func (n *Notifier) Notify(ctx context.Context, s Summary) error {
+	if n == nil || n.URL == "" {
+		return nil
+	}
+
+	format := n.Format
+	if format == nil {
+		format = FormatJSON
+	}
+	body, err := format(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	if n.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
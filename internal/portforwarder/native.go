@@ -0,0 +1,159 @@
+package portforwarder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// startNative forwards the local port to the target pod, or a ready pod backing the target
+// service, over an SPDY connection to the API server. Cancelling ctx tears the forward down;
+// the returned channel is closed once it has exited.
+func (pf *PortForwarder) startNative(ctx context.Context) (<-chan struct{}, error) {
+	podName := pf.serviceName
+	var ports []string
+	if pf.targetKind == TargetService {
+		svc, pod, err := pf.resolveServiceTarget(ctx)
+		if err != nil {
+			return nil, err
+		}
+		podName = pod.Name
+		for _, pair := range pf.portPairs() {
+			targetPort, err := serviceTargetPort(svc, pod, pair.remote)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, fmt.Sprintf("%d:%d", pair.local, targetPort))
+		}
+	} else {
+		for _, pair := range pf.portPairs() {
+			ports = append(ports, pair.String())
+		}
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(pf.restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SPDY round tripper: %w", err)
+	}
+
+	reqURL := pf.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pf.namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, reqURL)
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, []string{"localhost"}, ports, stopCh, readyCh, io.Discard, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create port-forward: %w", err)
+	}
+
+	pf.logger.Info("Forwarding to pod",
+		"ports", strings.Join(ports, ","), "namespace", pf.namespace, "pod", podName, string(pf.targetKind), pf.serviceName)
+
+	exited := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(exited)
+
+		err := fw.ForwardPorts()
+		if err != nil && ctx.Err() == nil {
+			pf.logger.Warn("Port-forward ended unexpectedly", "error", err)
+		}
+		errCh <- err
+	}()
+
+	// Tear the forward down when the context is cancelled
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stopCh)
+		case <-exited:
+		}
+	}()
+
+	select {
+	case <-readyCh:
+		return exited, nil
+	case err := <-errCh:
+		return nil, fmt.Errorf("starting port-forward: %w", err)
+	case <-ctx.Done():
+		<-exited
+		return nil, ctx.Err()
+	}
+}
+
+// resolveServiceTarget returns the service and a ready pod selected by it
+func (pf *PortForwarder) resolveServiceTarget(ctx context.Context) (*corev1.Service, *corev1.Pod, error) {
+	svc, err := pf.clientset.CoreV1().Services(pf.namespace).Get(ctx, pf.serviceName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get service %s: %w", pf.serviceName, err)
+	}
+	if len(svc.Spec.Selector) == 0 {
+		return nil, nil, fmt.Errorf("service %s has no pod selector", pf.serviceName)
+	}
+
+	pods, err := pf.clientset.CoreV1().Pods(pf.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods for service %s: %w", pf.serviceName, err)
+	}
+
+	for i := range pods.Items {
+		if isPodReady(&pods.Items[i]) {
+			return svc, &pods.Items[i], nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("service %s has no ready pods", pf.serviceName)
+}
+
+// serviceTargetPort maps a service port to the container port of the pod
+func serviceTargetPort(svc *corev1.Service, pod *corev1.Pod, port int) (int, error) {
+	for _, sp := range svc.Spec.Ports {
+		if int(sp.Port) != port {
+			continue
+		}
+		switch {
+		case sp.TargetPort.StrVal != "":
+			for _, container := range pod.Spec.Containers {
+				for _, cp := range container.Ports {
+					if cp.Name == sp.TargetPort.StrVal {
+						return int(cp.ContainerPort), nil
+					}
+				}
+			}
+			return 0, fmt.Errorf("pod %s has no container port named %s", pod.Name, sp.TargetPort.StrVal)
+		case sp.TargetPort.IntVal != 0:
+			return int(sp.TargetPort.IntVal), nil
+		default:
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("service %s does not expose port %d", svc.Name, port)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
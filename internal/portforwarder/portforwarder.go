@@ -3,92 +3,527 @@ package portforwarder
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
 	"net/url"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Defaults of waiting for a started forward to accept connections: the first probe comes
+// after defaultReadyInterval, the delay doubles up to defaultReadyMaxInterval, and the forward
+// is given up on after defaultReadyTimeout
+const (
+	defaultReadyInterval    = 100 * time.Millisecond
+	defaultReadyMaxInterval = 2 * time.Second
+	defaultReadyTimeout     = 30 * time.Second
 )
 
+// TargetKind is the kind of resource a forward connects to
+type TargetKind string
+
+const (
+	TargetService TargetKind = "service" // a ready pod backing the service
+	TargetPod     TargetKind = "pod"     // one specific pod
+)
+
+// portPair maps a remote port to the local port it is forwarded to
+type portPair struct {
+	remote int
+	local  int
+}
+
+func (p portPair) String() string {
+	return fmt.Sprintf("%d:%d", p.local, p.remote)
+}
+
 type PortForwarder struct {
 	namespace   string
-	serviceName string
+	serviceName string // name of the target, a service unless targetKind says otherwise
+	targetKind  TargetKind
 	port        int
 	localPort   int
-	cmd         *exec.Cmd
+	extraPorts  []portPair // further remote ports forwarded alongside port
 	running     bool
 	mu          sync.Mutex
-	done        chan struct{}
+	startMu     sync.Mutex    // serializes Start without blocking the accessors on pf.mu
+	done        chan struct{} // closed when the supervisor has exited
+	stop        func()        // cancels the supervisor and the active forward
+	err         error         // terminal error once reconnects are exhausted
+	ready       chan struct{} // closed once the forward accepted connections for the first time
+	readyOnce   sync.Once
+
+	// Native forwarding through the API server; kubectl is used when restConfig is nil
+	restConfig *rest.Config
+	clientset  kubernetes.Interface
+
+	// startForward replaces kubectl and native forwarding when set, for tests
+	startForward func(ctx context.Context) (<-chan struct{}, error)
+
+	// kubectl --context and --kubeconfig, empty leaves kubectl's own defaults
+	kubeContext string
+	kubeconfig  string
+
+	// HTTP path probed to decide readiness; empty means a plain TCP dial
+	readinessPath string
+	probeScheme   string       // http unless the target serves https
+	probeClient   *http.Client // client for the readiness probe, http.DefaultClient when nil
+
+	// Supervision of a forward that exits unexpectedly
+	maxReconnects    int
+	reconnectBackoff time.Duration
+
+	// How long kubectl gets to exit after SIGTERM before it is killed
+	stopGrace time.Duration
+
+	// Probing of a started forward until it accepts connections, see SetReadyWait
+	readyInterval    time.Duration
+	readyMaxInterval time.Duration
+	readyTimeout     time.Duration
+
+	// Retries of the initial start, e.g. while the service has no ready endpoints yet
+	maxStartAttempts int
+	startRetryDelay  time.Duration
+
+	logger *slog.Logger
 }
 
-func NewPortForwarder(namespace, serviceName string, port int) (*PortForwarder, error) {
-	localPort, err := getAvailablePort()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get available port: %w", err)
+// NewPortForwarder creates a forwarder for the service port. A zero localPort picks a free
+// local port; otherwise that exact port is used and must be available.
+func NewPortForwarder(namespace, serviceName string, port, localPort int) (*PortForwarder, error) {
+	if localPort == 0 {
+		var err error
+		localPort, err = getAvailablePort()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get available port: %w", err)
+		}
+	} else if err := checkPortAvailable(localPort); err != nil {
+		return nil, err
 	}
 
 	pf := &PortForwarder{
-		namespace:   namespace,
-		serviceName: serviceName,
-		port:        port,
-		localPort:   localPort,
-		done:        make(chan struct{}),
-	}
-	fmt.Printf("PortForwarder created: namespace=%s, service=%s, port=%d, localPort=%d\n",
-		namespace, serviceName, port, localPort)
+		namespace:        namespace,
+		serviceName:      serviceName,
+		targetKind:       TargetService,
+		port:             port,
+		localPort:        localPort,
+		reconnectBackoff: time.Second,
+		stopGrace:        3 * time.Second,
+		maxStartAttempts: 1,
+		readyInterval:    defaultReadyInterval,
+		readyMaxInterval: defaultReadyMaxInterval,
+		readyTimeout:     defaultReadyTimeout,
+		logger:           slog.Default(),
+		ready:            make(chan struct{}),
+	}
+	pf.logger.Info("PortForwarder created",
+		"namespace", namespace, "service", serviceName, "port", port, "local_port", localPort)
 	return pf, nil
 }
 
-func (pf *PortForwarder) Start(ctx context.Context) error {
+// UseNative makes Start forward through the API server with client-go instead of
+// shelling out to kubectl. The forward targets a ready pod backing the service.
+func (pf *PortForwarder) UseNative(config *rest.Config, clientset kubernetes.Interface) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.restConfig = config
+	pf.clientset = clientset
+}
+
+// SetReconnect makes the forwarder restart a forward that exits unexpectedly up to
+// maxReconnects consecutive times, waiting backoff before the first attempt and doubling
+// it for each following one. Zero reconnects keeps the forward down once it exits.
+func (pf *PortForwarder) SetReconnect(maxReconnects int, backoff time.Duration) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.maxReconnects = maxReconnects
+	pf.reconnectBackoff = backoff
+}
+
+// SetReadinessPath makes the forward count as ready only once an HTTP GET of path through
+// it returns 2xx. An empty path only checks that a TCP connection can be opened.
+func (pf *PortForwarder) SetReadinessPath(path string) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.readinessPath = path
+}
+
+// SetProbeTLS makes the readiness probe use HTTPS with the given client, which carries the
+// TLS settings of the target, e.g. a custom CA
+func (pf *PortForwarder) SetProbeTLS(client *http.Client) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.probeScheme = "https"
+	pf.probeClient = client
+}
+
+// SetStartRetry makes Start try the whole start sequence up to attempts times,
+// waiting delay between attempts
+func (pf *PortForwarder) SetStartRetry(attempts int, delay time.Duration) {
 	pf.mu.Lock()
 	defer pf.mu.Unlock()
+	if attempts < 1 {
+		attempts = 1
+	}
+	pf.maxStartAttempts = attempts
+	pf.startRetryDelay = delay
+}
+
+// SetReadyWait tunes how a started forward is probed until it accepts connections: the
+// first probe comes after interval, the delay doubles after each failed probe up to
+// maxInterval, and the forward fails once timeout has passed. Zero values keep the defaults
+// of 100ms, 2s and 30s; cold-start grids behind slow ingress may need a longer timeout.
+func (pf *PortForwarder) SetReadyWait(interval, maxInterval, timeout time.Duration) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if interval > 0 {
+		pf.readyInterval = interval
+	}
+	if maxInterval > 0 {
+		pf.readyMaxInterval = maxInterval
+	}
+	if timeout > 0 {
+		pf.readyTimeout = timeout
+	}
+}
+
+// SetStopGrace sets how long the kubectl process gets to exit after SIGTERM before it is killed
+func (pf *PortForwarder) SetStopGrace(grace time.Duration) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.stopGrace = grace
+}
+
+// SetLogger sets the logger used for the forward's progress, slog.Default() when nil
+func (pf *PortForwarder) SetLogger(logger *slog.Logger) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if logger == nil {
+		logger = slog.Default()
+	}
+	pf.logger = logger
+}
+
+// SetTarget points the forward at a service or a pod of the given name instead of the
+// service passed to NewPortForwarder
+func (pf *PortForwarder) SetTarget(kind TargetKind, name string) error {
+	if kind != TargetService && kind != TargetPod {
+		return fmt.Errorf("unknown target kind %q (expected %s or %s)", kind, TargetService, TargetPod)
+	}
+	if name == "" {
+		return fmt.Errorf("target name must not be empty")
+	}
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.targetKind = kind
+	pf.serviceName = name
+	return nil
+}
+
+// SetKubeConfig passes the kubeconfig context and file to kubectl, so a kubectl forward
+// targets the same cluster as the Kubernetes client. Empty values keep kubectl's defaults.
+// The native forwarder uses the config given to UseNative instead.
+func (pf *PortForwarder) SetKubeConfig(kubeContext, kubeconfig string) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.kubeContext = kubeContext
+	pf.kubeconfig = kubeconfig
+}
+
+// AddPorts forwards further remote ports alongside the primary one, each to a free local
+// port. It must be called before Start.
+func (pf *PortForwarder) AddPorts(remotes ...int) error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	for _, remote := range remotes {
+		if remote == pf.port || pf.lookupLocalPort(remote) != 0 {
+			continue
+		}
+		local, err := getAvailablePort()
+		if err != nil {
+			return fmt.Errorf("failed to get available port for %d: %w", remote, err)
+		}
+		pf.extraPorts = append(pf.extraPorts, portPair{remote: remote, local: local})
+	}
+	return nil
+}
+
+// LocalPortFor returns the local port a remote port is forwarded to, or 0 when it is not forwarded
+func (pf *PortForwarder) LocalPortFor(remote int) int {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if remote == pf.port {
+		return pf.localPort
+	}
+	return pf.lookupLocalPort(remote)
+}
+
+// lookupLocalPort finds the local port of an extra remote port; the caller holds pf.mu
+func (pf *PortForwarder) lookupLocalPort(remote int) int {
+	for _, pair := range pf.extraPorts {
+		if pair.remote == remote {
+			return pair.local
+		}
+	}
+	return 0
+}
+
+// portPairs returns the primary port pair followed by the extra ones
+func (pf *PortForwarder) portPairs() []portPair {
+	return append([]portPair{{remote: pf.port, local: pf.localPort}}, pf.extraPorts...)
+}
+
+// IsRunning reports whether the forward is started and has not been stopped or given up
+func (pf *PortForwarder) IsRunning() bool {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.running
+}
+
+// LocalPort returns the local port the forward listens on
+func (pf *PortForwarder) LocalPort() int {
+	return pf.localPort
+}
+
+// Ready returns a channel that is closed once the forward has accepted connections
+func (pf *PortForwarder) Ready() <-chan struct{} {
+	return pf.ready
+}
+
+// Err returns the terminal error of a forward that exited and could not be reconnected
+func (pf *PortForwarder) Err() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.err
+}
+
+// Start starts the forward and returns once it accepts connections, retrying the start as
+// configured with SetStartRetry. pf.mu is only held to read the settings and publish the
+// state, so the accessors report the state while a start is in progress.
+func (pf *PortForwarder) Start(ctx context.Context) error {
+	pf.startMu.Lock()
+	defer pf.startMu.Unlock()
 
+	pf.mu.Lock()
 	if pf.running {
+		pf.mu.Unlock()
 		return nil
 	}
-
-	// Create a child context that we can cancel when stopping
+	// Create a child context that we can cancel when stopping, also during the startup
 	childCtx, cancel := context.WithCancel(ctx)
+	pf.stop = cancel
+	pf.done = nil
+	maxAttempts := pf.maxStartAttempts
+	retryDelay := pf.startRetryDelay
+	logger := pf.logger
+	pf.mu.Unlock()
 
-	portString := fmt.Sprintf("%d:%d", pf.localPort, pf.port)
-	args := []string{
-		"port-forward",
-		"-n", pf.namespace,
-		fmt.Sprintf("service/%s", pf.serviceName),
-		portString,
+	fail := func(err error) error {
+		pf.mu.Lock()
+		pf.stop = nil
+		pf.mu.Unlock()
+		cancel()
+		return err
+	}
+
+	var exited <-chan struct{}
+	var err error
+	for attempt := 1; ; attempt++ {
+		if maxAttempts > 1 {
+			logger.Info("Starting port-forward", "attempt", attempt, "max_attempts", maxAttempts)
+		}
+		exited, err = pf.connect(childCtx)
+		if err == nil {
+			break
+		}
+		if attempt >= maxAttempts {
+			return fail(err)
+		}
+
+		logger.Warn("Port-forward start failed, retrying", "delay", retryDelay.String(), "error", err)
+		select {
+		case <-childCtx.Done():
+			return fail(childCtx.Err())
+		case <-time.After(retryDelay):
+		}
 	}
 
-	fmt.Printf("kubectl %s\n", strings.Join(args, " "))
-	pf.cmd = exec.CommandContext(childCtx, "kubectl", args...)
+	pf.mu.Lock()
+	if childCtx.Err() != nil {
+		// Stopped while starting
+		pf.mu.Unlock()
+		cancel()
+		<-exited
+		return childCtx.Err()
+	}
+	pf.running = true
+	pf.err = nil
+	pf.done = make(chan struct{})
+	go pf.supervise(childCtx, cancel, exited, pf.done)
+	pf.mu.Unlock()
+
+	return nil
+}
 
-	stderr, err := pf.cmd.StderrPipe()
+// connect starts a single forward and waits until it accepts connections.
+// The returned channel is closed once the forward exits.
+func (pf *PortForwarder) connect(ctx context.Context) (<-chan struct{}, error) {
+	attemptCtx, cancel := context.WithCancel(ctx)
+
+	start := pf.startKubectl
+	switch {
+	case pf.startForward != nil:
+		start = pf.startForward
+	case pf.restConfig != nil:
+		start = pf.startNative
+	}
+	exited, err := start(attemptCtx)
 	if err != nil {
 		cancel()
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return nil, err
 	}
 
-	if err := pf.cmd.Start(); err != nil {
+	// Wait for the port to become available
+	if err := pf.waitForConnection(attemptCtx, exited); err != nil {
+		cancel() // Clean up if connection fails
+		<-exited
+		return nil, fmt.Errorf("port-forward connection failed: %w", err)
+	}
+
+	go func() {
+		<-exited
 		cancel()
-		return fmt.Errorf("starting port-forward: %w", err)
+	}()
+	return exited, nil
+}
+
+// supervise restarts the forward when it exits while ctx is still active, giving up
+// after maxReconnects consecutive failed attempts
+func (pf *PortForwarder) supervise(ctx context.Context, cancel context.CancelFunc, exited <-chan struct{}, done chan struct{}) {
+	defer close(done)
+	defer cancel()
+
+	failures := 0
+	for {
+		<-exited
+		if ctx.Err() != nil {
+			break
+		}
+
+		if failures >= pf.maxReconnects {
+			err := fmt.Errorf("port-forward exited and %d reconnect attempt(s) failed", failures)
+			pf.logger.Error("Port-forward is down", "error", err)
+			pf.mu.Lock()
+			pf.err = err
+			pf.mu.Unlock()
+			break
+		}
+
+		backoff := pf.reconnectBackoff << failures
+		failures++
+		pf.logger.Warn("Port-forward lost, reconnecting",
+			"delay", backoff.String(), "attempt", failures, "max_attempts", pf.maxReconnects)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		next, err := pf.connect(ctx)
+		if err != nil {
+			pf.logger.Warn("Port-forward reconnect failed", "error", err)
+			closed := make(chan struct{})
+			close(closed)
+			exited = closed
+			continue
+		}
+		exited = next
+		failures = 0
+	}
+
+	pf.mu.Lock()
+	pf.running = false
+	pf.mu.Unlock()
+}
+
+// kubectlArgs builds the arguments of the kubectl port-forward command
+func (pf *PortForwarder) kubectlArgs() []string {
+	var args []string
+	if pf.kubeconfig != "" {
+		args = append(args, "--kubeconfig", pf.kubeconfig)
+	}
+	if pf.kubeContext != "" {
+		args = append(args, "--context", pf.kubeContext)
+	}
+	args = append(args,
+		"port-forward",
+		"-n", pf.namespace,
+		fmt.Sprintf("%s/%s", pf.targetKind, pf.serviceName),
+	)
+	for _, pair := range pf.portPairs() {
+		args = append(args, pair.String())
+	}
+	return args
+}
+
+// startKubectl spawns `kubectl port-forward` for the service. Cancelling ctx kills the process;
+// the returned channel is closed once the process has exited.
+func (pf *PortForwarder) startKubectl(ctx context.Context) (<-chan struct{}, error) {
+	args := pf.kubectlArgs()
+	pf.logger.Info("Running kubectl", "args", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	setProcessGroup(cmd)
+
+	exited := make(chan struct{})
+	grace := pf.stopGrace
+	logger := pf.logger
+
+	// On cancellation ask the process group to exit and only kill it after the grace period
+	cmd.Cancel = func() error {
+		err := terminateProcess(cmd)
+		go func() {
+			select {
+			case <-exited:
+			case <-time.After(grace):
+				logger.Warn("Port-forward did not exit after SIGTERM, killing it")
+				if err := killProcess(cmd); err != nil {
+					logger.Error("Failed to kill port-forward process", "error", err)
+				}
+			}
+		}()
+		return err
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting port-forward: %w", err)
 	}
 
 	// Handle process cleanup in a goroutine
 	go func() {
-		defer cancel() // Ensure context is cancelled when we're done
-		defer close(pf.done)
+		defer close(exited)
 
 		// Wait for the command to complete
-		if err := pf.cmd.Wait(); err != nil {
-			if childCtx.Err() == nil { // Only log if we haven't cancelled deliberately
-				fmt.Printf("port-forward process ended unexpectedly: %v\n", err)
+		if err := cmd.Wait(); err != nil {
+			if ctx.Err() == nil { // Only log if we haven't cancelled deliberately
+				logger.Warn("Port-forward process ended unexpectedly", "error", err)
 			}
 		}
-
-		pf.mu.Lock()
-		pf.running = false
-		pf.mu.Unlock()
 	}()
 
 	// Handle stderr output
@@ -97,7 +532,7 @@ func (pf *PortForwarder) Start(ctx context.Context) error {
 		for {
 			n, err := stderr.Read(buf)
 			if n > 0 {
-				fmt.Printf("kubectl stderr: %s", buf[:n])
+				logger.Warn("kubectl stderr", "output", strings.TrimSpace(string(buf[:n])))
 			}
 			if err != nil {
 				break
@@ -105,86 +540,151 @@ func (pf *PortForwarder) Start(ctx context.Context) error {
 		}
 	}()
 
-	// Wait for the port to become available
-	if err := pf.waitForConnection(childCtx); err != nil {
-		pf.Stop() // Clean up if connection fails
-		return fmt.Errorf("port-forward connection failed: %w", err)
-	}
-
-	pf.running = true
-	return nil
+	return exited, nil
 }
 
-func (pf *PortForwarder) waitForConnection(ctx context.Context) error {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
+// waitForConnection probes the forward with a doubling delay until it accepts connections,
+// see SetReadyWait
+func (pf *PortForwarder) waitForConnection(ctx context.Context, exited <-chan struct{}) error {
+	interval := pf.readyInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
-	timeout := time.After(30 * time.Second)
+	timeout := time.After(pf.readyTimeout)
 
 	addr := fmt.Sprintf("localhost:%d", pf.localPort)
 
+	failures := 0
+	var lastErr error
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-exited:
+			return fmt.Errorf("port-forward exited before becoming ready")
 		case <-timeout:
-			return fmt.Errorf("timeout waiting for port-forward to be ready")
-		case <-ticker.C:
-			conn, err := net.DialTimeout("tcp", addr, time.Second)
+			if lastErr == nil {
+				return fmt.Errorf("timeout after %v waiting for port-forward to be ready", pf.readyTimeout)
+			}
+			return fmt.Errorf("timeout after %v waiting for port-forward to be ready, %d failed dials, last: %w",
+				pf.readyTimeout, failures, lastErr)
+		case <-timer.C:
+			err := pf.probe(ctx, addr)
 			if err == nil {
-				conn.Close()
-				fmt.Printf("Port-forward is ready on %s\n", addr)
+				pf.logger.Info("Port-forward is ready", "addr", addr, "failed_dials", failures)
+				pf.readyOnce.Do(func() { close(pf.ready) })
 				return nil
 			}
+			failures++
+			lastErr = err
+			interval = min(2*interval, max(pf.readyMaxInterval, pf.readyInterval))
+			pf.logger.Debug("Port-forward is not ready yet", "addr", addr, "retry_in", interval.String(), "error", err)
+			timer.Reset(interval)
 		}
 	}
 }
 
+// probe checks the forward once, by TCP dial or by HTTP GET of the readiness path
+func (pf *PortForwarder) probe(ctx context.Context, addr string) error {
+	if pf.readinessPath == "" {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	scheme, client := "http", http.DefaultClient
+	if pf.probeScheme != "" {
+		scheme = pf.probeScheme
+	}
+	if pf.probeClient != nil {
+		client = pf.probeClient
+	}
+
+	probeURL := scheme + "://" + addr + "/" + strings.TrimPrefix(pf.readinessPath, "/")
+	req, err := http.NewRequestWithContext(probeCtx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("readiness probe returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func (pf *PortForwarder) Stop() {
 	pf.mu.Lock()
-	if !pf.running || pf.cmd == nil {
+	if pf.stop == nil {
 		pf.mu.Unlock()
 		return
 	}
+	// Cancelled under the lock, so a Start in progress sees it before publishing its state
+	pf.stop()
 	pf.running = false
-	cmd := pf.cmd
-	pf.cmd = nil
+	pf.stop = nil
+	done := pf.done
+	wait := pf.stopGrace + 5*time.Second
 	pf.mu.Unlock()
 
-	// Kill the process
-	if cmd.Process != nil {
-		if err := cmd.Process.Kill(); err != nil {
-			fmt.Printf("Error killing port-forward process: %v\n", err)
-		}
+	if done == nil {
+		// Still starting; Start tears the forward down and returns
+		return
 	}
 
-	// Wait for the process to be fully cleaned up
+	// Wait for the forward to be fully cleaned up
 	select {
-	case <-pf.done:
-		// Process has exited
-	case <-time.After(5 * time.Second):
-		fmt.Println("Warning: Timeout waiting for port-forward process to exit")
+	case <-done:
+		// Forward has exited
+	case <-time.After(wait):
+		pf.logger.Warn("Timeout waiting for port-forward process to exit")
 	}
 }
 
+// GetLocalURL rewrites a URL of the remote service to go through the forward. The URL's
+// port picks the forwarded port; URLs without a known port go to the primary one.
 func (pf *PortForwarder) GetLocalURL(remoteURL string) string {
 	u, err := url.Parse(remoteURL)
 	if err != nil {
-		fmt.Printf("Error parsing URL: %v\n", err)
+		pf.logger.Error("Failed to parse URL", "url", remoteURL, "error", err)
 		return remoteURL
 	}
 
 	parts := strings.Split(u.Host, ":")
 	hostname := "localhost"
 
+	localPort := pf.localPort
 	if len(parts) > 1 {
 		hostname = parts[0]
+		if remote, err := strconv.Atoi(parts[1]); err == nil {
+			if local := pf.LocalPortFor(remote); local != 0 {
+				localPort = local
+			}
+		}
 	}
-	u.Host = hostname + ":" + strconv.Itoa(pf.localPort)
+	u.Host = hostname + ":" + strconv.Itoa(localPort)
 
 	return u.String()
 }
 
+// checkPortAvailable verifies that the local port can be bound
+func checkPortAvailable(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return fmt.Errorf("local port %d is already in use: %w", port, err)
+	}
+	return listener.Close()
+}
+
 func getAvailablePort() (int, error) {
 	listener, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -0,0 +1,238 @@
+package portforwarder
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKubectlArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeContext string
+		kubeconfig  string
+		want        []string
+	}{
+		{
+			name: "kubectl defaults",
+			want: []string{"port-forward", "-n", "selenium", "service/selenium-hub"},
+		},
+		{
+			name:        "context",
+			kubeContext: "staging",
+			want:        []string{"--context", "staging", "port-forward", "-n", "selenium", "service/selenium-hub"},
+		},
+		{
+			name:        "context and kubeconfig",
+			kubeContext: "staging",
+			kubeconfig:  "/etc/selenium/kubeconfig",
+			want: []string{"--kubeconfig", "/etc/selenium/kubeconfig", "--context", "staging",
+				"port-forward", "-n", "selenium", "service/selenium-hub"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pf, err := NewPortForwarder("selenium", "selenium-hub", 4444, 0)
+			if err != nil {
+				t.Fatalf("NewPortForwarder() error = %v", err)
+			}
+			pf.SetKubeConfig(tt.kubeContext, tt.kubeconfig)
+
+			want := append(tt.want, fmt.Sprintf("%d:4444", pf.localPort))
+			if got := pf.kubectlArgs(); !slices.Equal(got, want) {
+				t.Errorf("kubectlArgs() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// fakeForward stands in for kubectl: each start serves the forward's local port until its
+// context is cancelled or drop ends it
+type fakeForward struct {
+	port int
+	gate chan struct{} // when set, starts only listen once it is closed
+
+	mu       sync.Mutex
+	starts   int
+	listener net.Listener
+}
+
+func (f *fakeForward) start(ctx context.Context) (<-chan struct{}, error) {
+	f.mu.Lock()
+	f.starts++
+	f.mu.Unlock()
+
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		if f.gate != nil {
+			select {
+			case <-f.gate:
+			case <-ctx.Done():
+				return
+			}
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", f.port))
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.listener = listener
+		f.mu.Unlock()
+		go func() {
+			<-ctx.Done()
+			listener.Close()
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return exited, nil
+}
+
+// drop ends the active forward as if kubectl had exited
+func (f *fakeForward) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listener.Close()
+}
+
+func (f *fakeForward) startCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.starts
+}
+
+// newFakeForwarder returns a forwarder started through a fakeForward
+func newFakeForwarder(t *testing.T) (*PortForwarder, *fakeForward) {
+	t.Helper()
+	pf, err := NewPortForwarder("selenium", "selenium-hub", 4444, 0)
+	if err != nil {
+		t.Fatalf("NewPortForwarder() error = %v", err)
+	}
+	fake := &fakeForward{port: pf.LocalPort()}
+	pf.startForward = fake.start
+	pf.SetReadyWait(5*time.Millisecond, 20*time.Millisecond, 5*time.Second)
+	t.Cleanup(pf.Stop)
+	return pf, fake
+}
+
+// eventually fails the test when cond does not hold within a few seconds
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	pf, fake := newFakeForwarder(t)
+	pf.SetReconnect(2, 10*time.Millisecond)
+	if err := pf.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	fake.drop()
+	eventually(t, "the reconnect", func() bool { return fake.startCount() == 2 })
+	eventually(t, "the reconnected forward", func() bool {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", pf.LocalPort()))
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	})
+	if !pf.IsRunning() || pf.Err() != nil {
+		t.Errorf("IsRunning() = %v, Err() = %v, want a running forward without error", pf.IsRunning(), pf.Err())
+	}
+}
+
+func TestReconnectGivesUp(t *testing.T) {
+	pf, fake := newFakeForwarder(t)
+	pf.SetReconnect(0, 10*time.Millisecond)
+	if err := pf.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	fake.drop()
+	eventually(t, "the forward to be given up", func() bool { return !pf.IsRunning() })
+	if pf.Err() == nil {
+		t.Error("Err() = nil, want the error of the lost forward")
+	}
+	if got := fake.startCount(); got != 1 {
+		t.Errorf("starts = %d, want 1", got)
+	}
+}
+
+func TestAccessorsDuringStart(t *testing.T) {
+	pf, fake := newFakeForwarder(t)
+	fake.gate = make(chan struct{})
+
+	started := make(chan error, 1)
+	go func() { started <- pf.Start(context.Background()) }()
+	eventually(t, "the start", func() bool { return fake.startCount() == 1 })
+
+	running := make(chan bool, 1)
+	go func() { running <- pf.IsRunning() }()
+	select {
+	case got := <-running:
+		if got {
+			t.Error("IsRunning() = true while starting, want false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("IsRunning() blocked while starting")
+	}
+	select {
+	case <-pf.Ready():
+		t.Error("Ready() is closed before the forward accepted connections")
+	default:
+	}
+
+	close(fake.gate)
+	if err := <-started; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if !pf.IsRunning() {
+		t.Error("IsRunning() = false after Start, want true")
+	}
+	select {
+	case <-pf.Ready():
+	default:
+		t.Error("Ready() is not closed after Start")
+	}
+}
+
+func TestStopDuringStart(t *testing.T) {
+	pf, fake := newFakeForwarder(t)
+	fake.gate = make(chan struct{})
+
+	started := make(chan error, 1)
+	go func() { started <- pf.Start(context.Background()) }()
+	eventually(t, "the start", func() bool { return fake.startCount() == 1 })
+
+	pf.Stop()
+	select {
+	case err := <-started:
+		if err == nil {
+			t.Error("Start() error = nil after Stop, want an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start() did not return after Stop")
+	}
+	if pf.IsRunning() {
+		t.Error("IsRunning() = true after Stop, want false")
+	}
+}
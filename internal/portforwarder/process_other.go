@@ -0,0 +1,18 @@
+//go:build !unix
+
+package portforwarder
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are not available
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess kills the process, there is no gentler signal to send here
+func terminateProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// killProcess forcibly kills the process
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}